@@ -0,0 +1,48 @@
+package uri
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A BoolFormat holds the strings that true and false expand to. The zero
+// value expands them as "true" and "false".
+type BoolFormat struct {
+	True, False string
+}
+
+// Common boolean representations.
+var (
+	BoolTrueFalse = BoolFormat{"true", "false"}
+	BoolOneZero   = BoolFormat{"1", "0"}
+	BoolYesNo     = BoolFormat{"yes", "no"}
+)
+
+// format formats value if it is a boolean and f is not the zero value, and
+// reports whether it did. Booleans that format themselves, such as those
+// implementing fmt.Stringer, are left alone.
+func (f BoolFormat) format(value interface{}) (string, bool) {
+	if f == (BoolFormat{}) || isScalar(value) {
+		return "", false
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Bool {
+		return "", false
+	}
+	if v.Bool() {
+		return f.True, true
+	}
+	return f.False, true
+}
+
+// parseBoolOption sets f from a bool= option of a uri tag, which gives the
+// strings for true and false separated by a slash, as in "bool=1/0" or
+// "bool=yes/no".
+func parseBoolOption(f *BoolFormat, option string) {
+	if !strings.HasPrefix(option, "bool=") {
+		return
+	}
+	if i := strings.IndexByte(option, '/'); i >= 0 {
+		*f = BoolFormat{option[len("bool="):i], option[i+1:]}
+	}
+}
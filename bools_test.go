@@ -0,0 +1,62 @@
+package uri
+
+import "testing"
+
+func TestExpandBools(t *testing.T) {
+	type flagBool bool
+	values := map[string]interface{}{"a": true, "b": false, "c": flagBool(true), "list": []bool{true, false}}
+	tests := []struct {
+		format BoolFormat
+		out    string
+	}{
+		{BoolFormat{}, "?a=true&b=false&c=true&list=true,false"},
+		{BoolTrueFalse, "?a=true&b=false&c=true&list=true,false"},
+		{BoolOneZero, "?a=1&b=0&c=1&list=1,0"},
+		{BoolYesNo, "?a=yes&b=no&c=yes&list=yes,no"},
+		{BoolFormat{"on", ""}, "?a=on&b=&c=on&list=on,"},
+	}
+	for _, test := range tests {
+		out, err := MustParse("{?a,b,c,list}").ExpandWithOpts(values, ExpandOpts{Bools: test.format})
+		if err != nil || out != test.out {
+			t.Errorf("%+v: want %s, got %s, %v", test.format, test.out, out, err)
+		}
+	}
+}
+
+type switchBool bool
+
+func (b switchBool) String() string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func TestExpandBoolStringers(t *testing.T) {
+	type filter struct {
+		Light switchBool `uri:"light,bool=1/0"`
+	}
+	values := map[string]interface{}{"a": true, "light": switchBool(true), "list": []switchBool{false}}
+	out, err := MustParse("{?a,light,list}").ExpandWithOpts(values, ExpandOpts{Bools: BoolOneZero})
+	if expected := "?a=1&light=on&list=off"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+	out, err = MustParse("{?light}").Expand(filter{Light: true})
+	if expected := "?light=on"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+}
+
+func TestExpandBoolTags(t *testing.T) {
+	type filter struct {
+		Active   bool `uri:"active,bool=yes/no"`
+		Archived bool `uri:"archived,bool=1/0"`
+		Deleted  bool `uri:"deleted,omitempty,bool=1/0"`
+		Plain    bool `uri:"plain"`
+		Bad      bool `uri:"bad,bool=y"`
+	}
+	out, err := MustParse("{?active,archived,deleted,plain,bad}").ExpandWithOpts(filter{Active: true}, ExpandOpts{Bools: BoolFormat{"T", "F"}})
+	if expected := "?active=yes&archived=0&plain=F&bad=F"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+}
@@ -0,0 +1,147 @@
+package uri
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A Builder assembles a URI, or a template, from its components, escaping
+// each component as RFC 3986 requires. Its methods return the Builder so
+// that calls can be chained:
+//
+//	NewBuilder().Scheme("https").Host("example.com").PathSegment("users", "a b").Query("q", "x&y").String()
+//
+// returns "https://example.com/users/a%20b?q=x%26y". PathTemplate adds an
+// expression to the path, in which case Template parses the result as a
+// template.
+type Builder struct {
+	scheme      string
+	host        string
+	port        string
+	path        strings.Builder
+	query       []string
+	fragment    string
+	hasFragment bool
+	templated   bool
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Scheme sets the scheme, such as "https".
+func (b *Builder) Scheme(scheme string) *Builder {
+	b.scheme = strings.ToLower(scheme)
+	return b
+}
+
+// Host sets the host. A host that contains a colon is taken to be an IPv6
+// address and is enclosed in brackets.
+func (b *Builder) Host(host string) *Builder {
+	if strings.Contains(host, ":") {
+		b.host = "[" + host + "]"
+	} else {
+		b.host = escapeComponent(host, "")
+	}
+	return b
+}
+
+// Port sets the port.
+func (b *Builder) Port(port int) *Builder {
+	b.port = strconv.Itoa(port)
+	return b
+}
+
+// PathSegment appends path segments, each preceded by "/". Characters that
+// cannot occur in a segment, including "/", are percent-encoded.
+func (b *Builder) PathSegment(segments ...string) *Builder {
+	for _, segment := range segments {
+		b.path.WriteByte('/')
+		b.path.WriteString(escapeComponent(segment, ":@"))
+	}
+	return b
+}
+
+// PathTemplate appends a template expression to the path. An expression
+// that does not begin with the "/" operator, such as "{id}", is preceded by
+// "/", so that it expands to a segment of its own.
+func (b *Builder) PathTemplate(expression string) *Builder {
+	if !strings.HasPrefix(expression, "{/") {
+		b.path.WriteByte('/')
+	}
+	b.path.WriteString(expression)
+	b.templated = true
+	return b
+}
+
+// Query appends a query parameter. Both key and value are percent-encoded
+// except for unreserved characters and the sub-delims other than "&", "="
+// and "+", which delimit query parameters.
+func (b *Builder) Query(key, value string) *Builder {
+	b.query = append(b.query, escapeComponent(key, "")+"="+escapeComponent(value, ""))
+	return b
+}
+
+// Fragment sets the fragment.
+func (b *Builder) Fragment(fragment string) *Builder {
+	b.fragment, b.hasFragment = escapeComponent(fragment, ":@/?"), true
+	return b
+}
+
+// String returns the URI, or the template if PathTemplate was used.
+func (b *Builder) String() string {
+	var s strings.Builder
+	if b.scheme != "" {
+		s.WriteString(b.scheme)
+		s.WriteByte(':')
+	}
+	if b.host != "" || b.port != "" {
+		s.WriteString("//")
+		s.WriteString(b.host)
+		if b.port != "" {
+			s.WriteByte(':')
+			s.WriteString(b.port)
+		}
+	}
+	s.WriteString(b.path.String())
+	if len(b.query) > 0 {
+		s.WriteByte('?')
+		s.WriteString(strings.Join(b.query, "&"))
+	}
+	if b.hasFragment {
+		s.WriteByte('#')
+		s.WriteString(b.fragment)
+	}
+	return s.String()
+}
+
+// URI parses the result with ParseURI. It fails if PathTemplate was used.
+func (b *Builder) URI() (*URI, error) {
+	if b.templated {
+		return nil, errors.New("builder holds a template")
+	}
+	return ParseURI(b.String())
+}
+
+// Template parses the result as a template.
+func (b *Builder) Template() (*Template, error) {
+	return Parse(b.String())
+}
+
+// escapeComponent percent-encodes the characters of s other than unreserved
+// characters, sub-delims and extra. The sub-delims "&", "=" and "+" are
+// percent-encoded as well, since they delimit query parameters.
+func escapeComponent(s, extra string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) || strings.IndexByte("!$'()*,;", c) >= 0 || strings.IndexByte(extra, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.Write(pctEncode([]byte{c}))
+		}
+	}
+	return b.String()
+}
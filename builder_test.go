@@ -0,0 +1,51 @@
+package uri
+
+import (
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		b   *Builder
+		out string
+	}{
+		{NewBuilder().Scheme("HTTPS").Host("example.com").PathSegment("users", "a b/c").Query("q", "x&y=z").Fragment("top"), "https://example.com/users/a%20b%2Fc?q=x%26y%3Dz#top"},
+		{NewBuilder().Scheme("http").Host("::1").Port(8080).PathSegment("a:b@c"), "http://[::1]:8080/a:b@c"},
+		{NewBuilder().PathSegment("x").Query("a", "1").Query("a", "2+3"), "/x?a=1&a=2%2B3"},
+		{NewBuilder().Scheme("https").Host("example.com").PathSegment("users").PathTemplate("{id}").PathTemplate("{/rest*}"), "https://example.com/users/{id}{/rest*}"},
+		{NewBuilder().PathSegment("{x}"), "/%7Bx%7D"},
+	}
+	for _, test := range tests {
+		if out := test.b.String(); out != test.out {
+			t.Errorf("want %s, got %s", test.out, out)
+		}
+	}
+}
+
+func TestBuilderURIAndTemplate(t *testing.T) {
+	u, err := NewBuilder().Scheme("https").Host("example.com").PathSegment("a b").URI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "example.com" || u.Path != "/a%20b" {
+		t.Errorf("unexpected URI %+v", *u)
+	}
+	b := NewBuilder().Scheme("https").Host("example.com").PathSegment("users").PathTemplate("{id}")
+	if _, err := b.URI(); err == nil {
+		t.Error("want error for a templated builder")
+	}
+	template, err := b.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := template.Expand(map[string]string{"id": "a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "https://example.com/users/a%2Fb" {
+		t.Errorf("unexpected expansion %s", out)
+	}
+	if _, err := NewBuilder().PathTemplate("{id").Template(); err == nil {
+		t.Error("want error for an invalid expression")
+	}
+}
@@ -0,0 +1,224 @@
+package uri
+
+import (
+	"container/list"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache memoizes expansions: it keeps the results of Expand for recently
+// used pairs of a template and the values of its variables, so that
+// expanding the same template with equal values again, such as pagination
+// links, returns the result without expanding the template. It is safe for
+// concurrent use.
+//
+// Templates are told apart by identity, not by their text, so the template
+// should be parsed once and reused. Values are compared by content, and only
+// the variables of the template are taken into account, unless it has a
+// preprocessing function. Values whose content cannot be compared, such as
+// io.Reader values, channels, functions including Lazy values, and
+// templates, bypass the cache, and so do templates with a truncate handler,
+// which is called on every expansion.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	entries map[memoKey]*list.Element
+}
+
+type memoKey struct {
+	t      *Template
+	values string
+}
+
+type memoEntry struct {
+	key      memoKey
+	expanded string
+	expires  time.Time
+}
+
+// NewCache returns a Cache that keeps up to size expansions, evicting the
+// least recently used ones first, each for at most ttl. A size of zero or
+// less disables the cache, and a ttl of zero or less keeps expansions until
+// they are evicted.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{size: size, ttl: ttl, now: time.Now, order: list.New(), entries: make(map[memoKey]*list.Element)}
+}
+
+// Expand expands t with value like t.Expand, returning a cached result if
+// t was expanded with equal values before. Errors are not cached.
+func (c *Cache) Expand(t *Template, value interface{}) (string, error) {
+	if c.size <= 0 || t.static || t.truncateHandler != nil {
+		return t.Expand(value)
+	}
+	values, err := t.values(value, &ExpandOpts{})
+	if err != nil {
+		return "", err
+	}
+	canonical, ok := canonicalValues(t, values)
+	if !ok {
+		return t.Expand(value)
+	}
+	key := memoKey{t, canonical}
+	c.mu.Lock()
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*memoEntry)
+		if c.ttl <= 0 || c.now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			return entry.expanded, nil
+		}
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	expanded, err := t.Expand(value)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists {
+		c.entries[key] = c.order.PushFront(&memoEntry{key, expanded, c.now().Add(c.ttl)})
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*memoEntry).key)
+		}
+	}
+	return expanded, nil
+}
+
+// Len returns the number of cached expansions, including expired ones that
+// were not yet evicted.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge removes all cached expansions.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[memoKey]*list.Element)
+}
+
+// canonicalValues returns a string that is equal for equal values of the
+// variables of t, and reports whether the values can be compared at all.
+// If t has a preprocessing function, which may derive its variables from
+// any of the values, all values are taken into account.
+func canonicalValues(t *Template, values map[string]interface{}) (string, bool) {
+	names := t.Names()
+	if t.preProcess != nil {
+		names = sortedMapKeys(values)
+	}
+	var b strings.Builder
+	for _, name := range names {
+		v, exists := values[name]
+		if !exists {
+			continue
+		}
+		fmt.Fprintf(&b, "%q=", name)
+		if !writeCanonical(&b, v, 0) {
+			return "", false
+		}
+		b.WriteByte(';')
+	}
+	return b.String(), true
+}
+
+func writeCanonical(b *strings.Builder, v interface{}, depth int) bool {
+	if depth >= defaultMaxDepth {
+		return false
+	}
+	if v != nil && isNil(v) {
+		fmt.Fprintf(b, "%T:nil", v)
+		return true
+	}
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("nil")
+		return true
+	case *Template, io.Reader:
+		return false
+	case time.Time:
+		fmt.Fprintf(b, "time:%q", v.Round(0).String())
+		return true
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		fmt.Fprintf(b, "%T:%q", v, text)
+		return err == nil
+	case fmt.Stringer:
+		fmt.Fprintf(b, "%T:%q", v, v.String())
+		return true
+	case error:
+		fmt.Fprintf(b, "%T:%q", v, v.Error())
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(b, "%T:%v", v, v)
+	case reflect.String:
+		fmt.Fprintf(b, "%T:%q", v, v)
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			fmt.Fprintf(b, "%T:nil", v)
+			return true
+		}
+		return writeCanonical(b, value.Elem().Interface(), depth+1)
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(b, "%T[", v)
+		for i := 0; i < value.Len(); i++ {
+			if !writeCanonical(b, value.Index(i).Interface(), depth+1) {
+				return false
+			}
+			b.WriteByte(',')
+		}
+		b.WriteByte(']')
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		fmt.Fprintf(b, "%T{", v)
+		for _, k := range keys {
+			if !writeCanonical(b, k.Interface(), depth+1) {
+				return false
+			}
+			b.WriteByte(':')
+			if !writeCanonical(b, value.MapIndex(k).Interface(), depth+1) {
+				return false
+			}
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	case reflect.Struct:
+		fmt.Fprintf(b, "%T{", v)
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if !writeCanonical(b, value.Field(i).Interface(), depth+1) {
+				return false
+			}
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package uri
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2, 0)
+	template := MustParse("/items{?page,limit}")
+	calls := 0
+	counting := template.WithPreProcess(func(values map[string]interface{}) (map[string]interface{}, error) {
+		calls++
+		return values, nil
+	})
+	tests := []struct {
+		values   interface{}
+		expected string
+		calls    int
+	}{
+		{map[string]interface{}{"page": 1, "limit": 10}, "/items?page=1&limit=10", 1},
+		{map[string]interface{}{"page": 1, "limit": 10}, "/items?page=1&limit=10", 1},
+		{map[string]string{"page": "1", "limit": "10"}, "/items?page=1&limit=10", 2},
+		{map[string]interface{}{"page": 2, "limit": 10}, "/items?page=2&limit=10", 3},
+		{map[string]interface{}{"page": 1, "limit": 10}, "/items?page=1&limit=10", 4},
+		{map[string]interface{}{"page": 1, "limit": 10, "sort": "asc"}, "/items?page=1&limit=10", 5},
+		{struct {
+			Page  int `uri:"page"`
+			Limit int `uri:"limit"`
+		}{2, 10}, "/items?page=2&limit=10", 6},
+	}
+	for i, test := range tests {
+		actual, err := c.Expand(counting, test.values)
+		if err != nil || actual != test.expected {
+			t.Errorf("%d: expected %q, got %q, %v", i, test.expected, actual, err)
+		}
+		if calls != test.calls {
+			t.Errorf("%d: expected %d expansions, got %d", i, test.calls, calls)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 cached expansions, got %d", c.Len())
+	}
+	c.Purge()
+	for _, values := range []map[string]interface{}{{"page": 3}, {"page": 3, "unused": "x"}} {
+		c.Expand(template, values)
+	}
+	if _, cached := c.entries[memoKey{template, `"page"=int:3;`}]; !cached || c.Len() != 1 {
+		t.Errorf("expected unused values to be ignored, got %d cached expansions", c.Len())
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected no cached expansions after Purge, got %d", c.Len())
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewCache(8, time.Minute)
+	c.now = func() time.Time { return now }
+	calls := 0
+	template := MustParse("/{id}").WithPreProcess(func(values map[string]interface{}) (map[string]interface{}, error) {
+		calls++
+		return values, nil
+	})
+	values := map[string]interface{}{"id": "a"}
+	for _, step := range []struct {
+		advance time.Duration
+		calls   int
+	}{{0, 1}, {30 * time.Second, 1}, {31 * time.Second, 2}, {59 * time.Second, 2}} {
+		now = now.Add(step.advance)
+		if _, err := c.Expand(template, values); err != nil {
+			t.Fatal(err)
+		}
+		if calls != step.calls {
+			t.Errorf("after %v: expected %d expansions, got %d", step.advance, step.calls, calls)
+		}
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	c := NewCache(8, 0)
+	template := MustParse("/{a}")
+	for _, value := range []interface{}{
+		strings.NewReader("x"),
+		func() (interface{}, error) { return "x", nil },
+		closedChan(),
+		MustParse("x"),
+	} {
+		if _, err := c.Expand(template, map[string]interface{}{"a": value}); err != nil {
+			t.Errorf("%T: %v", value, err)
+		}
+	}
+	if _, err := c.Expand(template, 42); err == nil {
+		t.Errorf("expected an error for invalid values")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected no cached expansions, got %d", c.Len())
+	}
+	if actual, err := NewCache(0, 0).Expand(template, map[string]interface{}{"a": "x"}); err != nil || actual != "/x" {
+		t.Errorf("expected %q, got %q, %v", "/x", actual, err)
+	}
+}
+
+func TestCacheTruncateHandler(t *testing.T) {
+	c := NewCache(8, 0)
+	calls := 0
+	template := MustParse("/{id:3}").WithTruncateHandler(func(name, original, truncated string) {
+		calls++
+	})
+	for i := 0; i < 3; i++ {
+		if actual, err := c.Expand(template, map[string]interface{}{"id": "abcdef"}); err != nil || actual != "/abc" {
+			t.Errorf("expected %q, got %q, %v", "/abc", actual, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls of the truncate handler, got %d", calls)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected no cached expansions, got %d", c.Len())
+	}
+}
+
+func TestCacheCanonical(t *testing.T) {
+	template := MustParse("{a}")
+	tests := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{1, 1, true},
+		{1, "1", false},
+		{1, int64(1), false},
+		{[]string{"x", "y"}, []string{"x", "y"}, true},
+		{[]string{"x,y"}, []string{"x", "y"}, false},
+		{map[string]int{"a": 1, "b": 2}, map[string]int{"b": 2, "a": 1}, true},
+		{Null, nil, false},
+		{time.Unix(0, 0).UTC(), time.Unix(0, 0).UTC(), true},
+		{(*time.Time)(nil), (*time.Time)(nil), true},
+		{(*time.Time)(nil), nil, false},
+		{(*url.URL)(nil), (*time.Time)(nil), false},
+	}
+	for _, test := range tests {
+		a, okA := canonicalValues(template, map[string]interface{}{"a": test.a})
+		b, okB := canonicalValues(template, map[string]interface{}{"a": test.b})
+		if !okA || !okB || (a == b) != test.equal {
+			t.Errorf("%#v, %#v: expected equal %v, got %q, %q", test.a, test.b, test.equal, a, b)
+		}
+	}
+}
+
+func TestCacheNilPointers(t *testing.T) {
+	c := NewCache(8, 0)
+	template := MustParse("/events{?since,next}")
+	for i := 0; i < 2; i++ {
+		actual, err := c.Expand(template, map[string]interface{}{"since": (*time.Time)(nil), "next": (*url.URL)(nil)})
+		if expected := "/events"; err != nil || actual != expected {
+			t.Errorf("expected %q, got %q, %v", expected, actual, err)
+		}
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected 1 cached expansion, got %d", c.Len())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(4, 0)
+	template := MustParse("/items{?page}")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				page := (i + j) % 6
+				actual, err := c.Expand(template, map[string]interface{}{"page": page})
+				if expected := "/items?page=" + string(rune('0'+page)); err != nil || actual != expected {
+					t.Errorf("expected %q, got %q, %v", expected, actual, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func closedChan() chan string {
+	ch := make(chan string)
+	close(ch)
+	return ch
+}
@@ -0,0 +1,159 @@
+// Command uri expands and matches URI templates from the command line.
+//
+// Usage:
+//
+//	uri expand [-json] [-env] template [name=value ...]
+//	uri match template uri
+//
+// Expand prints the expansion of the template. Its values are taken from
+// the environment with -env, then from a JSON object read from standard
+// input with -json, then from the name=value arguments, each source
+// overriding the ones before it. A name given several times is a list:
+//
+//	$ uri expand 'https://x{/a,b}{?q*}' a=1 b=2 q=hi q=there
+//	https://x/1/2?q=hi&q=there
+//
+// Match prints the variables that a uri matching the template defines, as
+// a JSON object, and exits with status 1 if it does not match:
+//
+//	$ uri match '/users/{id}{?q}' '/users/42?q=go'
+//	{"id":"42","q":"go"}
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cognicraft/uri"
+)
+
+const usage = `usage:
+  uri expand [-json] [-env] template [name=value ...]
+  uri match template uri
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Environ()))
+}
+
+// run executes the command line args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, environ []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "expand":
+		err = expand(args[1:], stdin, stdout, stderr, environ)
+	case "match":
+		err = match(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = errUsage
+	}
+	switch err {
+	case nil:
+		return 0
+	case errUsage:
+		fmt.Fprint(stderr, usage)
+		return 2
+	case errNoMatch:
+		return 1
+	}
+	fmt.Fprintln(stderr, "uri:", err)
+	return 1
+}
+
+type cliError string
+
+func (e cliError) Error() string { return string(e) }
+
+const (
+	errUsage   = cliError("usage")
+	errNoMatch = cliError("no match")
+)
+
+func expand(args []string, stdin io.Reader, stdout, stderr io.Writer, environ []string) error {
+	flags := flag.NewFlagSet("expand", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	fromJSON := flags.Bool("json", false, "read values from a JSON object on standard input")
+	fromEnv := flags.Bool("env", false, "read values from environment variables")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+	template, err := uri.Parse(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	if *fromEnv {
+		for _, kv := range environ {
+			if i := strings.IndexByte(kv, '='); i > 0 {
+				values[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	if *fromJSON {
+		var object map[string]interface{}
+		d := json.NewDecoder(stdin)
+		d.UseNumber()
+		if err := d.Decode(&object); err != nil {
+			return fmt.Errorf("reading JSON values: %v", err)
+		}
+		for k, v := range object {
+			values[k] = v
+		}
+	}
+	lists := make(map[string][]string)
+	for _, arg := range flags.Args()[1:] {
+		i := strings.IndexByte(arg, '=')
+		if i <= 0 {
+			return fmt.Errorf("expected name=value, got %q", arg)
+		}
+		lists[arg[:i]] = append(lists[arg[:i]], arg[i+1:])
+	}
+	for name, list := range lists {
+		if len(list) == 1 {
+			values[name] = list[0]
+		} else {
+			values[name] = list
+		}
+	}
+	out, err := template.Expand(template.FilterValues(values))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, out)
+	return nil
+}
+
+func match(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	template, err := uri.Parse(args[0])
+	if err != nil {
+		return err
+	}
+	values, ok := template.Match(args[1])
+	if !ok {
+		fmt.Fprintln(stderr, "uri: no match")
+		return errNoMatch
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(data))
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		env    []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"expand", "https://x{/a,b}{?q}", "a=1", "b=2", "q=hi"}, "", nil, 0, "https://x/1/2?q=hi\n", ""},
+		{[]string{"expand", "{?q*}", "q=a", "q=b c"}, "", nil, 0, "?q=a&q=b%20c\n", ""},
+		{[]string{"expand", "-json", "/users/{id}{?tags}", "id=7"}, `{"id": 1, "tags": ["a", "b"]}`, nil, 0, "/users/7?tags=a,b\n", ""},
+		{[]string{"expand", "-env", "{HOME}{/USER}"}, "", []string{"HOME=/home/ann", "USER=ann", "BAD"}, 0, "%2Fhome%2Fann/ann\n", ""},
+		{[]string{"expand", "-env", "-json", "{a,b}"}, `{"a": "json"}`, []string{"a=env", "b=env"}, 0, "json,env\n", ""},
+		{[]string{"expand", "{a"}, "", nil, 1, "", "uri: unclosed expression in \"{a}\" at offset 0\n"},
+		{[]string{"expand", "{a}", "novalue"}, "", nil, 1, "", "uri: expected name=value, got \"novalue\"\n"},
+		{[]string{"expand", "-json", "{a}"}, `[`, nil, 1, "", "uri: reading JSON values: unexpected EOF\n"},
+		{[]string{"match", "/users/{id}{?q}", "/users/42?q=go"}, "", nil, 0, "{\"id\":\"42\",\"q\":\"go\"}\n", ""},
+		{[]string{"match", "/users/{id}", "/items/42"}, "", nil, 1, "", "uri: no match\n"},
+		{[]string{"match", "/users/{id}"}, "", nil, 2, "", usage},
+		{[]string{"expand"}, "", nil, 2, "", usage},
+		{[]string{"frobnicate"}, "", nil, 2, "", usage},
+		{nil, "", nil, 2, "", usage},
+		{[]string{"help"}, "", nil, 0, usage, ""},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr, test.env)
+		if status != test.status || stdout.String() != test.stdout || stderr.String() != test.stderr {
+			t.Errorf("%q: got %d, %q, %q, want %d, %q, %q", test.args, status, stdout.String(), stderr.String(), test.status, test.stdout, test.stderr)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package uri
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FromColonPattern returns the template of a route pattern in the colon
+// syntax of routers such as Express and Rails, so that
+// "/users/:id/posts/:postId" becomes "/users/{id}/posts/{postId}". A
+// parameter followed by "?" is optional and includes the "/" before it, so
+// "/files/:name?" becomes "/files{/name}", and a glob such as "*path"
+// becomes the reserved expansion "{+path}". A parameter may be followed by a
+// regular expression in parentheses, as in ":id(\\d+)", which becomes a
+// Pattern constraint of the variable.
+func FromColonPattern(pattern string) (*Template, error) {
+	var b strings.Builder
+	constraints := make(map[string]Constraint)
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '{' || c == '}' {
+			return nil, fmt.Errorf("unexpected %c at offset %d", c, i)
+		}
+		if c != ':' && c != '*' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		name := colonName.FindString(pattern[i+1:])
+		if name == "" && c == ':' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if name == "" {
+			return nil, fmt.Errorf("glob without a name at offset %d", i)
+		}
+		i += 1 + len(name)
+		if i < len(pattern) && pattern[i] == '(' && c == ':' {
+			end := closingParen(pattern, i)
+			if end < 0 {
+				return nil, fmt.Errorf("parameter %s: unclosed pattern", name)
+			}
+			expr := pattern[i+1 : end]
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("parameter %s: %v", name, err)
+			}
+			constraints[name] = Pattern(expr)
+			i = end + 1
+		}
+		switch {
+		case c == '*':
+			b.WriteString("{+" + name + "}")
+		case i < len(pattern) && pattern[i] == '?':
+			if !strings.HasSuffix(b.String(), "/") {
+				return nil, fmt.Errorf("optional parameter %s does not follow a /", name)
+			}
+			raw := b.String()
+			b.Reset()
+			b.WriteString(raw[:len(raw)-1] + "{/" + name + "}")
+			i++
+		default:
+			b.WriteString("{" + name + "}")
+		}
+	}
+	t, err := Parse(b.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(constraints) > 0 {
+		t.constraints = constraints
+	}
+	return t, nil
+}
+
+var colonName = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*")
+
+func isNameByte(c byte) bool {
+	return isAlnum(c) || c == '_'
+}
+
+// closingParen returns the index of the parenthesis that closes the one at
+// s[open], or -1. Escaped parentheses are skipped.
+func closingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// ToColonPattern returns the route pattern in colon syntax that matches the
+// same paths as the template. It is the inverse of FromColonPattern: "{id}"
+// becomes ":id", "{/name}" becomes "/:name?" and "{+path}" becomes "*path".
+// An error is returned for templates that colon syntax cannot describe, such
+// as those with other operators, with several variables in an expression,
+// with modifiers, with variable names that are not identifiers, or with a
+// literal that continues a parameter or contains "*" or ":" before a name.
+// Constraints are not carried over.
+func (t *Template) ToColonPattern() (string, error) {
+	var b strings.Builder
+	for i, p := range t.parts {
+		if p.terms == nil {
+			if i > 0 && len(p.raw) > 0 && (isNameByte(p.raw[0]) || p.raw[0] == '?' || p.raw[0] == '(') {
+				return "", fmt.Errorf("literal %q continues a parameter", p.raw)
+			}
+			for j := 0; j < len(p.raw); j++ {
+				if p.raw[j] == '*' || p.raw[j] == ':' && colonName.MatchString(p.raw[j+1:]) {
+					return "", fmt.Errorf("literal %q cannot be part of a colon pattern", p.raw)
+				}
+			}
+			b.WriteString(p.raw)
+			continue
+		}
+		if len(p.terms) > 1 {
+			return "", fmt.Errorf("expression {%s}: several variables are not supported", p.expr)
+		}
+		term := p.terms[0]
+		if term.explode || term.truncate > 0 {
+			return "", fmt.Errorf("expression {%s}: modifiers are not supported", p.expr)
+		}
+		if colonName.FindString(term.name) != term.name {
+			return "", fmt.Errorf("variable %s is not a valid parameter name", term.name)
+		}
+		switch p.op {
+		case 0:
+			b.WriteString(":" + term.name)
+		case '/':
+			b.WriteString("/:" + term.name + "?")
+		case '+':
+			b.WriteString("*" + term.name)
+		default:
+			return "", fmt.Errorf("expression {%s}: operator %c is not supported", p.expr, p.op)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,101 @@
+package uri
+
+import "testing"
+
+func TestFromColonPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"/users/:id/posts/:postId", "/users/{id}/posts/{postId}"},
+		{"/files/:name?", "/files{/name}"},
+		{"/assets/*path", "/assets/{+path}"},
+		{"/flights/:from-:to", "/flights/{from}-{to}"},
+		{"/users/:id(\\d+)", "/users/{id}"},
+		{"/static", "/static"},
+		{"http://example.com:8080/:id", "http://example.com:8080/{id}"},
+	}
+	for _, test := range tests {
+		template, err := FromColonPattern(test.pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.pattern, err)
+			continue
+		}
+		if template.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.pattern, test.expected, template.String())
+		}
+	}
+}
+
+func TestFromColonPatternConstraint(t *testing.T) {
+	template, err := FromColonPattern("/users/:id(\\d+)/:tab(a|(b))?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/users/{id}{/tab}"; template.String() != expected {
+		t.Errorf("expected %q, got %q", expected, template.String())
+	}
+	for uri, expected := range map[string]bool{"/users/12": true, "/users/12/b": true, "/users/abc": false, "/users/12/c": false} {
+		if _, ok := template.Match(uri); ok != expected {
+			t.Errorf("%s: expected match %v", uri, expected)
+		}
+	}
+}
+
+func TestFromColonPatternError(t *testing.T) {
+	tests := []string{
+		"/assets/*",
+		"/users/:id(\\d+",
+		"/users/:id([)",
+		"/users:id?",
+		"/users/{id}",
+	}
+	for _, test := range tests {
+		if template, err := FromColonPattern(test); err == nil {
+			t.Errorf("%s: expected error, got %q", test, template)
+		}
+	}
+}
+
+func TestToColonPattern(t *testing.T) {
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"/users/{id}/posts/{postId}", "/users/:id/posts/:postId"},
+		{"/files{/name}", "/files/:name?"},
+		{"/assets/{+path}", "/assets/*path"},
+		{"/flights/{from}-{to}", "/flights/:from-:to"},
+		{"http://example.com:8080/{id}", "http://example.com:8080/:id"},
+	}
+	for _, test := range tests {
+		pattern, err := MustParse(test.template).ToColonPattern()
+		if err != nil || pattern != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.template, test.expected, pattern, err)
+			continue
+		}
+		if template, err := FromColonPattern(pattern); err != nil || template.String() != test.template {
+			t.Errorf("%s: round trip through %q gave %q, %v", test.template, pattern, template, err)
+		}
+	}
+}
+
+func TestToColonPatternError(t *testing.T) {
+	tests := []string{
+		"/a/{x,y}",
+		"/a/{id*}",
+		"/a/{name:3}",
+		"/a{?q}",
+		"/a{.ext}",
+		"/a/{id}x",
+		"/a/{id}?",
+		"/a/{user.name}",
+		"/a/:b/{id}",
+		"/a/*/{id}",
+	}
+	for _, test := range tests {
+		if pattern, err := MustParse(test).ToColonPattern(); err == nil {
+			t.Errorf("%s: expected error, got %q", test, pattern)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package uri
+
+import "bytes"
+
+// A Compiled template expands like the template it was compiled from, with
+// the options given to Compile, but decides once rather than on every
+// expansion how to expand each part of the template. It is safe for
+// concurrent use.
+type Compiled struct {
+	t     *Template
+	opts  ExpandOpts
+	parts []compiledPart
+}
+
+type compiledPart func(buf *bytes.Buffer, values map[string]interface{}, e *expander) error
+
+// Compile prepares the template for repeated expansion with opts. Literals
+// are written as is, and expressions whose variables are all strings are
+// expanded without the type switch that other values go through, with their
+// separators computed in advance.
+func (t *Template) Compile(opts ...ExpandOption) *Compiled {
+	c := &Compiled{t: t, parts: make([]compiledPart, len(t.parts))}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	for i := range t.parts {
+		c.parts[i] = c.compilePart(&t.parts[i])
+	}
+	return c
+}
+
+func (c *Compiled) compilePart(p *templatePart) compiledPart {
+	if p.terms == nil {
+		raw := p.raw
+		return func(buf *bytes.Buffer, values map[string]interface{}, e *expander) error {
+			buf.WriteString(raw)
+			return nil
+		}
+	}
+	e := &expander{opts: &c.opts}
+	if len(c.opts.Hooks) > 0 || len(c.opts.Encoders) > 0 || e.sortQuery(p) {
+		return p.expand
+	}
+	for _, term := range p.terms {
+		if _, constrained := c.t.constraints[term.name]; constrained {
+			return p.expand
+		}
+	}
+	first, sep := p.first, e.sep(p)
+	defaults := c.opts.Defaults
+	return func(buf *bytes.Buffer, values map[string]interface{}, e *expander) error {
+		for _, term := range p.terms {
+			if v, exists := values[term.name]; exists {
+				if _, isString := v.(string); !isString {
+					return p.expand(buf, values, e)
+				}
+			} else if _, hasDefault := defaults[term.name]; hasDefault {
+				return p.expand(buf, values, e)
+			}
+		}
+		n := 0
+		for _, term := range p.terms {
+			v, exists := values[term.name]
+			if !exists {
+				continue
+			}
+			if n == 0 {
+				buf.WriteString(first)
+			} else {
+				buf.WriteString(sep)
+			}
+			n++
+			p.expandString(buf, term, v.(string), e)
+		}
+		return nil
+	}
+}
+
+// Template returns the template c was compiled from.
+func (c *Compiled) Template() *Template {
+	return c.t
+}
+
+// Expand expands the template like Template.ExpandWithOpts with the options
+// given to Compile.
+func (c *Compiled) Expand(value interface{}) (string, error) {
+	if c.t.static && c.t.preProcess == nil {
+		if err := c.t.checkValues(value); err != nil {
+			return "", err
+		}
+		return c.t.raw, nil
+	}
+	buf := getBuffer(c.t.sizeHint())
+	defer putBuffer(buf)
+	if err := c.expand(buf, value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// AppendExpand appends the expansion of the template to dst like
+// Template.AppendExpand, with the options given to Compile.
+func (c *Compiled) AppendExpand(dst []byte, value interface{}) ([]byte, error) {
+	buf := getBuffer(c.t.sizeHint())
+	defer putBuffer(buf)
+	if err := c.expand(buf, value); err != nil {
+		return dst, err
+	}
+	return append(dst, buf.Bytes()...), nil
+}
+
+func (c *Compiled) expand(buf *bytes.Buffer, value interface{}) error {
+	e := getExpander(&c.opts)
+	defer putExpander(e)
+	values, err := e.prepare(c.t, value)
+	if err != nil {
+		return err
+	}
+	for _, part := range c.parts {
+		if err := part(buf, values, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package uri
+
+import (
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	values := map[string]interface{}{
+		"id":   "a/b",
+		"q":    "x y",
+		"list": []string{"red", "green"},
+		"keys": map[string]interface{}{"a": "1", "b": "2"},
+		"n":    42,
+		"nil":  nil,
+		"long": "abcdefgh",
+	}
+	templates := []string{
+		"/static",
+		"/items{/id}{?q,n}",
+		"{+id}{#q}",
+		"{?q,missing,long:3}",
+		"{;list*,keys}",
+		"{.id,nil}{&q}",
+		"{?keys*}{&missing}",
+	}
+	opts := [][]ExpandOption{
+		nil,
+		{WithSortQuery()},
+		{WithSeparator("|"), WithLowerHex()},
+		{WithDefaults(map[string]interface{}{"missing": "d"})},
+		{WithHook(ExpandHookFunc(func(name string, v interface{}) (interface{}, error) { return v, nil }))},
+	}
+	for _, raw := range templates {
+		template := MustParse(raw)
+		for i, opt := range opts {
+			var o ExpandOpts
+			for _, set := range opt {
+				set(&o)
+			}
+			want, wantErr := template.ExpandWithOpts(values, o)
+			compiled := template.Compile(opt...)
+			got, err := compiled.Expand(values)
+			if got != want || (err == nil) != (wantErr == nil) {
+				t.Errorf("%s, options %d: Compiled.Expand = %q, %v, want %q, %v", raw, i, got, err, want, wantErr)
+			}
+			appended, err := compiled.AppendExpand([]byte("x"), values)
+			if string(appended) != "x"+want || err != nil {
+				t.Errorf("%s, options %d: Compiled.AppendExpand = %q, %v, want %q", raw, i, appended, err, "x"+want)
+			}
+		}
+	}
+	compiled := MustParse("/{id}").Compile(WithStrict())
+	if compiled.Template().String() != "/{id}" {
+		t.Errorf("unexpected template %q", compiled.Template())
+	}
+	if _, err := compiled.Expand(map[string]interface{}{}); err == nil {
+		t.Errorf("want undefined variable error")
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	template := MustParse("http://localhost:8080/{id}{?date,name}")
+	values := map[string]interface{}{"id": "foo", "date": "2017-07-13", "name": "a b"}
+	b.Run("Expand", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			template.Expand(values)
+		}
+	})
+	b.Run("Compiled", func(b *testing.B) {
+		compiled := template.Compile()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			compiled.Expand(values)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+// Package conformance runs the test cases of the uritemplate-test suite
+// (https://github.com/uri-templates/uritemplate-test) against an
+// implementation of RFC 6570, such as package uri itself or a wrapper
+// around it.
+//
+// The suite is a set of JSON files, such as spec-examples.json and
+// extended-tests.json, each holding groups of test cases that share a set
+// of variables:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.TestFile(t, "testdata/spec-examples.json", conformance.URI)
+//	}
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cognicraft/uri"
+)
+
+// A Suite holds the groups of test cases of one file of the suite by name.
+type Suite map[string]Group
+
+// A Group is a set of test cases that are expanded with the same variables.
+type Group struct {
+	// Level is the level of RFC 6570 the test cases require, or 0.
+	Level     int                    `json:"level"`
+	Variables map[string]interface{} `json:"variables"`
+	TestCases []Case                 `json:"testcases"`
+}
+
+// A Case is a template together with its acceptable expansions.
+type Case struct {
+	Template string
+	// Expected holds the acceptable expansions. Several are given where
+	// the order of associative array members is not defined.
+	Expected []string
+	// Error is set if the template must fail to parse or expand.
+	Error bool
+}
+
+// UnmarshalJSON decodes a test case of the form [template, expected],
+// where expected is a string, a list of strings, or false.
+func (c *Case) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("test case has %d elements, want 2", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &c.Template); err != nil {
+		return err
+	}
+	var expected interface{}
+	if err := json.Unmarshal(raw[1], &expected); err != nil {
+		return err
+	}
+	switch e := expected.(type) {
+	case string:
+		c.Expected = []string{e}
+	case []interface{}:
+		for _, v := range e {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("unexpected expansion %v of %s", v, c.Template)
+			}
+			c.Expected = append(c.Expected, s)
+		}
+	case bool:
+		if e {
+			return errors.New("unexpected expansion true of " + c.Template)
+		}
+		c.Error = true
+	default:
+		return fmt.Errorf("unexpected expansion %v of %s", expected, c.Template)
+	}
+	return nil
+}
+
+// Load decodes a file of the suite. Numbers in variables are decoded as
+// json.Number, so they expand exactly as written.
+func Load(r io.Reader) (Suite, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	var s Suite
+	if err := d.Decode(&s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadFile decodes the file of the suite at path.
+func LoadFile(path string) (Suite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// An Expander parses template and expands it with variables.
+type Expander func(template string, variables map[string]interface{}) (string, error)
+
+// URI expands template with package uri.
+func URI(template string, variables map[string]interface{}) (string, error) {
+	t, err := uri.Parse(template)
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(variables)
+}
+
+// A Failure describes a test case whose expansion was not acceptable.
+type Failure struct {
+	Group string
+	Case  Case
+	// Got and Err are the results of the expansion.
+	Got string
+	Err error
+}
+
+func (f Failure) String() string {
+	switch {
+	case f.Case.Error:
+		return fmt.Sprintf("%s: %s: want an error, got %q", f.Group, f.Case.Template, f.Got)
+	case f.Err != nil:
+		return fmt.Sprintf("%s: %s: %v", f.Group, f.Case.Template, f.Err)
+	}
+	return fmt.Sprintf("%s: %s: want %s, got %q", f.Group, f.Case.Template, quoteAll(f.Case.Expected), f.Got)
+}
+
+func quoteAll(expected []string) string {
+	quoted := make([]string, len(expected))
+	for i, s := range expected {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// Run expands the test cases of s with expand and returns those that fail,
+// in the order of their group names.
+func Run(s Suite, expand Expander) []Failure {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var failures []Failure
+	for _, name := range names {
+		group := s[name]
+		for _, c := range group.TestCases {
+			got, err := expand(c.Template, group.Variables)
+			if c.Error {
+				if err == nil {
+					failures = append(failures, Failure{name, c, got, nil})
+				}
+			} else if err != nil || !contains(c.Expected, got) {
+				failures = append(failures, Failure{name, c, got, err})
+			}
+		}
+	}
+	return failures
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TestFile loads the file of the suite at path and reports every failing
+// test case of expand as an error of t.
+func TestFile(t testing.TB, path string, expand Expander) {
+	t.Helper()
+	s, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range Run(s, expand) {
+		t.Error(f)
+	}
+}
@@ -0,0 +1,58 @@
+package conformance
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// examples.json holds a selection of the examples of RFC 6570 and a few
+// failure cases in the format of the suite. It is not a copy of the suite.
+func TestURI(t *testing.T) {
+	TestFile(t, "testdata/examples.json", URI)
+}
+
+func TestRun(t *testing.T) {
+	s, err := Load(strings.NewReader(`{
+		"Group": {
+			"variables": {"a": "x", "n": 1.50},
+			"testcases": [["{a}", "x"], ["{n}", ["1.5", "1.50"]], ["{b", false], ["{a}", "y"]]
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := Run(s, func(template string, variables map[string]interface{}) (string, error) {
+		if template == "{b" {
+			return "", nil
+		}
+		if template == "{n}" {
+			return "", errors.New("boom")
+		}
+		return URI(template, variables)
+	})
+	want := []string{
+		`Group: {n}: boom`,
+		`Group: {b: want an error, got ""`,
+		`Group: {a}: want "y", got "x"`,
+	}
+	if len(failures) != len(want) {
+		t.Fatalf("want %d failures, got %v", len(want), failures)
+	}
+	for i, f := range failures {
+		if f.String() != want[i] {
+			t.Errorf("want %s, got %s", want[i], f)
+		}
+	}
+	if out, err := URI("{n}", s["Group"].Variables); err != nil || out != "1.50" {
+		t.Errorf("want numbers expanded as written, got %q, %v", out, err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, data := range []string{`{"G": {"testcases": [["{a}"]]}}`, `{"G": {"testcases": [["{a}", true]]}}`, `{"G": {"testcases": [["{a}", 1]]}}`, `[`} {
+		if _, err := Load(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: want error", data)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package uri
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Constraint restricts the values of a variable, both when a template is
+// expanded and when it is matched. See Template.Constrain.
+type Constraint interface {
+	// CheckValue returns an error if value may not be expanded. It is
+	// called with scalar values and with each element of lists and each
+	// value of associative arrays.
+	CheckValue(value interface{}) error
+	// CheckString returns an error if the percent-decoded string s, which
+	// was matched as a value, is not acceptable.
+	CheckString(s string) error
+}
+
+// A ConstraintError reports a value that violates the constraint of its
+// variable.
+type ConstraintError struct {
+	Name  string
+	Value interface{}
+	Err   error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("variable %s: value %q %v", e.Name, fmt.Sprint(e.Value), e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// Constrain returns a copy of t in which the variable name is restricted by
+// c, replacing any earlier constraint of name. Expansion fails with a
+// *ConstraintError if a defined value of name violates c, and Match does not
+// match a uri whose value of name violates it; MatchChecked reports why.
+//
+//	items := uri.MustParse("/items/{id}").Constrain("id", uri.Int())
+func (t *Template) Constrain(name string, c Constraint) *Template {
+	constrained := t.Clone()
+	constrained.constraints = make(map[string]Constraint, len(t.constraints)+1)
+	for k, v := range t.constraints {
+		constrained.constraints[k] = v
+	}
+	constrained.constraints[name] = c
+	return constrained
+}
+
+// checkValue checks value, which is normalized, against the constraint of
+// the variable name in e's template, if any.
+func (e *expander) checkValue(name string, value interface{}) error {
+	c, exists := e.template.constraints[name]
+	if !exists {
+		return nil
+	}
+	check := func(v interface{}) error {
+		if err := c.CheckValue(v); err != nil {
+			return &ConstraintError{Name: name, Value: v, Err: err}
+		}
+		return nil
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			if err := check(element); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for _, k := range sortedMapKeys(v) {
+			if err := check(v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Ordered:
+		for _, kv := range v {
+			if err := check(kv.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(value)
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MatchChecked matches uri against the template like Match, but returns an
+// error describing why it does not match: a *ConstraintError if a matched
+// value violates the constraint of its variable.
+func (t *Template) MatchChecked(uri string) (map[string]string, error) {
+	values, ok := t.matchUnchecked(uri)
+	if !ok {
+		return nil, errors.New("uri does not match template")
+	}
+	if err := t.checkMatch(values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// checkMatch checks matched values against the constraints of t. The
+// values of exploded variables are checked element by element.
+func (t *Template) checkMatch(values map[string]string) error {
+	if len(t.constraints) == 0 {
+		return nil
+	}
+	exploded := make(map[string]bool)
+	for _, p := range t.parts {
+		for _, term := range p.terms {
+			exploded[term.name] = exploded[term.name] || term.explode
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c, exists := t.constraints[name]
+		if !exists {
+			continue
+		}
+		elements := []string{values[name]}
+		if exploded[name] {
+			elements = strings.Split(values[name], ",")
+		}
+		for _, s := range elements {
+			if err := c.CheckString(s); err != nil {
+				return &ConstraintError{Name: name, Value: s, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// ConstraintFunc returns a Constraint that calls check with matched values
+// and with the string form of expanded values, as formatted by fmt.Sprint.
+func ConstraintFunc(check func(s string) error) Constraint {
+	return funcConstraint(check)
+}
+
+type funcConstraint func(s string) error
+
+func (f funcConstraint) CheckValue(value interface{}) error {
+	return f(fmt.Sprint(value))
+}
+
+func (f funcConstraint) CheckString(s string) error {
+	return f(s)
+}
+
+// Int returns a Constraint that accepts integers: values of integer types,
+// and strings that strconv.ParseInt accepts in base 10.
+func Int() Constraint {
+	return intConstraint{}
+}
+
+type intConstraint struct{}
+
+var errNotInt = errors.New("is not an integer")
+
+func (intConstraint) CheckValue(value interface{}) error {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	case reflect.String:
+		return intConstraint{}.CheckString(reflect.ValueOf(value).String())
+	}
+	return errNotInt
+}
+
+func (intConstraint) CheckString(s string) error {
+	if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+		return errNotInt
+	}
+	return nil
+}
+
+// Pattern returns a Constraint that accepts the values whose string form,
+// as formatted by fmt.Sprint, matches the regular expression expr in full.
+// It panics if expr does not compile.
+func Pattern(expr string) Constraint {
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+	return ConstraintFunc(func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("does not match %s", expr)
+		}
+		return nil
+	})
+}
+
+// OneOf returns a Constraint that accepts only the given values, compared
+// with the string form of values as formatted by fmt.Sprint.
+func OneOf(allowed ...string) Constraint {
+	return ConstraintFunc(func(s string) error {
+		for _, a := range allowed {
+			if s == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("is not one of %s", strings.Join(allowed, ", "))
+	})
+}
@@ -0,0 +1,126 @@
+package uri
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConstrainExpand(t *testing.T) {
+	template := MustParse("/items/{id}{?tags*}").Constrain("id", Int()).Constrain("tags", OneOf("a", "b"))
+	tests := []struct {
+		values   map[string]interface{}
+		expected string
+		invalid  string
+	}{
+		{map[string]interface{}{"id": 42}, "/items/42", ""},
+		{map[string]interface{}{"id": "7", "tags": []string{"a", "b"}}, "/items/7?tags=a&tags=b", ""},
+		{map[string]interface{}{"id": uint8(3), "tags": map[string]string{"x": "a"}}, "/items/3?x=a", ""},
+		{map[string]interface{}{}, "/items/", ""},
+		{map[string]interface{}{"id": "abc"}, "", "id"},
+		{map[string]interface{}{"id": 1.5}, "", "id"},
+		{map[string]interface{}{"id": 1, "tags": []string{"a", "c"}}, "", "tags"},
+		{map[string]interface{}{"id": 1, "tags": map[string]string{"x": "z"}}, "", "tags"},
+	}
+	for _, test := range tests {
+		actual, err := template.Expand(test.values)
+		if test.invalid != "" {
+			var ce *ConstraintError
+			if !errors.As(err, &ce) || ce.Name != test.invalid {
+				t.Errorf("%v: expected constraint error for %s, got %q, %v", test.values, test.invalid, actual, err)
+			}
+			continue
+		}
+		if err != nil || actual != test.expected {
+			t.Errorf("%v: expected %q, got %q, %v", test.values, test.expected, actual, err)
+		}
+		compiled, err := template.Compile().Expand(test.values)
+		if err != nil || compiled != test.expected {
+			t.Errorf("%v: compiled: expected %q, got %q, %v", test.values, test.expected, compiled, err)
+		}
+	}
+	if _, err := template.Compile().Expand(map[string]interface{}{"id": "abc"}); err == nil {
+		t.Errorf("compiled: expected constraint error")
+	}
+}
+
+func TestConstrainMatch(t *testing.T) {
+	template := MustParse("/items/{id}{/path*}").Constrain("id", Int()).Constrain("path", Pattern("[a-z]+"))
+	tests := []struct {
+		uri      string
+		expected map[string]string
+		invalid  string
+	}{
+		{"/items/42", map[string]string{"id": "42"}, ""},
+		{"/items/42/a/b", map[string]string{"id": "42", "path": "a,b"}, ""},
+		{"/items/abc", nil, "id"},
+		{"/items/1/a/B", nil, "path"},
+	}
+	for _, test := range tests {
+		values, ok := template.Match(test.uri)
+		checked, err := template.MatchChecked(test.uri)
+		if test.invalid != "" {
+			var ce *ConstraintError
+			if ok {
+				t.Errorf("%s: expected no match, got %v", test.uri, values)
+			}
+			if !errors.As(err, &ce) || ce.Name != test.invalid {
+				t.Errorf("%s: expected constraint error for %s, got %v", test.uri, test.invalid, err)
+			}
+			continue
+		}
+		if !ok || err != nil {
+			t.Errorf("%s: expected match, got %v, %v", test.uri, ok, err)
+			continue
+		}
+		for k, v := range test.expected {
+			if values[k] != v || checked[k] != v {
+				t.Errorf("%s: expected %s=%q, got %q and %q", test.uri, k, v, values[k], checked[k])
+			}
+		}
+	}
+	if _, err := template.MatchChecked("/other"); err == nil {
+		t.Errorf("expected an error for a uri that does not match")
+	}
+}
+
+func TestConstrainCopies(t *testing.T) {
+	template := MustParse("/items/{id}")
+	constrained := template.Constrain("id", Int())
+	if _, err := template.Expand(map[string]interface{}{"id": "abc"}); err != nil {
+		t.Errorf("original template is constrained: %v", err)
+	}
+	derived := []*Template{
+		constrained.Join(MustParse("{?q}")),
+		MustParse("/v1").Join(constrained),
+		constrained.Select("id"),
+		constrained.Clone(),
+	}
+	for _, d := range derived {
+		if _, err := d.Expand(map[string]interface{}{"id": "abc"}); err == nil {
+			t.Errorf("%s: constraint was not kept", d)
+		}
+	}
+	renamed := constrained.RenameVar("id", "key")
+	if _, err := renamed.Expand(map[string]interface{}{"key": "abc"}); err == nil {
+		t.Errorf("%s: constraint was not renamed", renamed)
+	}
+}
+
+func TestConstrainMux(t *testing.T) {
+	mux := NewMux()
+	mux.HandleTemplate("", MustParse("/items/{id}").Constrain("id", Int()), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("int"))
+	}))
+	mux.Handle("/items/{name}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("name"))
+	}))
+	for uri, expected := range map[string]string{"/items/12": "int", "/items/new": "name"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+		if rec.Body.String() != expected {
+			t.Errorf("%s: expected %q, got %q", uri, expected, rec.Body.String())
+		}
+	}
+}
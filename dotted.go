@@ -0,0 +1,56 @@
+package uri
+
+import "strings"
+
+// ParseDotted parses a URI template in which variable names are field paths
+// into a single root value, similar to text/template: "{.User.Name}" expands
+// the Name field of the User field of the value passed to Expand, and "{.}"
+// expands the root value itself. Path elements are resolved against struct
+// fields (honoring uri tags) and map keys; the leading "." is optional.
+//
+// RFC 6570 uses "." as the label operator, which conflicts with this syntax.
+// In a dotted template a single leading "." always starts a field path; the
+// label operator is written by doubling the dot, so "{..Ext}" is the label
+// expansion of the Ext field.
+func ParseDotted(raw string) (*Template, error) {
+	return parse(raw, true)
+}
+
+func (t *Template) resolveDotted(root interface{}, opts *ExpandOpts) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, p := range t.parts {
+		for _, term := range p.terms {
+			if v, ok := resolvePath(root, term.name, opts); ok {
+				values[term.name] = v
+			}
+		}
+	}
+	return values
+}
+
+func resolvePath(root interface{}, path string, opts *ExpandOpts) (interface{}, bool) {
+	path = strings.TrimPrefix(path, ".")
+	current := root
+	if path == "" {
+		return current, current != nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		if current == nil {
+			return nil, false
+		}
+		m, isMap := current.(map[string]interface{})
+		if !isMap {
+			if m, isMap = stringMap(current); !isMap {
+				if m, isMap = struct2map(current, opts); !isMap {
+					return nil, false
+				}
+			}
+		}
+		v, exists := m[name]
+		if !exists {
+			return nil, false
+		}
+		current = v
+	}
+	return current, true
+}
@@ -0,0 +1,93 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseDotted(t *testing.T) {
+	type owner struct {
+		Name string
+		ID   int `uri:"id"`
+	}
+	type repo struct {
+		Owner owner
+		Name  string
+		Ext   string
+		Tags  map[string]interface{}
+	}
+	root := repo{
+		Owner: owner{Name: "jtacoma", ID: 7},
+		Name:  "uritemplates",
+		Ext:   "json",
+		Tags:  map[string]interface{}{"lang": "go"},
+	}
+	tests := []struct {
+		raw  string
+		root interface{}
+		out  string
+	}{
+		{"/repos/{.Owner.Name}/{.Name}", root, "/repos/jtacoma/uritemplates"},
+		{"/repos/{Owner.Name}/{Name}", root, "/repos/jtacoma/uritemplates"},
+		{"/users/{.Owner.id}", root, "/users/7"},
+		{"/repos/{.Name}{..Ext}", root, "/repos/uritemplates.json"},
+		{"/repos{/.Owner.Name,.Name}", root, "/repos/jtacoma/uritemplates"},
+		{"/repos/{.Name}{?.Tags.lang}", root, "/repos/uritemplates?.Tags.lang=go"},
+		{"/repos/{.Name}{?.Missing,.Owner.Missing}", root, "/repos/uritemplates"},
+		{"{.}", "a b", "a%20b"},
+		{"{.}", nil, ""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := ParseDotted(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.Expand(test.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestParseDottedNilPointer(t *testing.T) {
+	type user struct{ Name string }
+	type root struct{ User *user }
+	template, err := ParseDotted("/u{/.User.Name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []interface{}{root{User: nil}, (*root)(nil), &root{}} {
+		out, err := template.Expand(value)
+		if err != nil || out != "/u" {
+			t.Errorf("%#v: want /u, got %q, %v", value, out, err)
+		}
+	}
+}
+
+func TestParseDottedStringMaps(t *testing.T) {
+	type root struct {
+		M map[string]string
+		P *map[string]int
+	}
+	template, err := ParseDotted("/u/{.M.k}{?.P.n,.M.missing}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := map[string]int{"n": 2}
+	tests := []interface{}{
+		root{M: map[string]string{"k": "v"}, P: &p},
+		map[string]interface{}{"M": map[string]string{"k": "v"}, "P": &p},
+	}
+	for _, value := range tests {
+		out, err := template.Expand(value)
+		if want := "/u/v?.P.n=2"; err != nil || out != want {
+			t.Errorf("%#v: want %s, got %q, %v", value, want, out, err)
+		}
+	}
+}
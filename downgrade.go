@@ -0,0 +1,65 @@
+package uri
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Downgrade returns a template that requires at most the given RFC 6570
+// conformance level, as described for ValidateLevel, for clients that
+// implement only that level. Expressions of several variables are split into
+// expressions of one variable each, and below level 3 the prefixes,
+// separators and names that the operators ".", "/", ";", "?" and "&" add are
+// written as literals, so that "{?a,b}" becomes "?a={a}&b={b}". The result
+// expands like t as long as every variable is defined and not empty.
+//
+// An error is returned if t uses features that have no equivalent at the
+// given level: the prefix and explode modifiers below level 4, and the "+"
+// and "#" operators at level 1.
+func (t *Template) Downgrade(level int) (*Template, error) {
+	if err := t.ValidateLevel(level); err == nil {
+		return t, nil
+	} else if level < 1 || level > 4 {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.terms == nil {
+			b.WriteString(p.raw)
+			continue
+		}
+		if p.level() == 4 || level == 1 && p.allowReserved {
+			return nil, fmt.Errorf("expression {%s} cannot be downgraded to level %d", p.expr, level)
+		}
+		if p.level() <= level {
+			b.WriteString(p.text(p.terms))
+			continue
+		}
+		for i, term := range p.terms {
+			switch {
+			case p.allowReserved && i == 0:
+				b.WriteString(p.text(p.terms[:1]))
+				continue
+			case p.allowReserved:
+				b.WriteString(",{+" + term.name + "}")
+				continue
+			case i == 0:
+				b.WriteString(p.first)
+			default:
+				b.WriteString(p.sep)
+			}
+			if p.named {
+				b.WriteString(term.name + "=")
+			}
+			b.WriteString("{" + term.name + "}")
+		}
+	}
+	downgraded, err := parse(b.String(), t.dotted)
+	if err != nil {
+		return nil, err
+	}
+	downgraded.truncateHandler = t.truncateHandler
+	downgraded.preProcess = t.preProcess
+	downgraded.constraints = t.constraints
+	return downgraded, nil
+}
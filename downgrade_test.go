@@ -0,0 +1,78 @@
+package uri
+
+import "testing"
+
+func TestDowngrade(t *testing.T) {
+	values := map[string]interface{}{"a": "x y", "b": "1/2", "c": []string{"p", "q"}, "m": map[string]string{"k": "v"}}
+	tests := []struct {
+		template string
+		level    int
+		expected string
+	}{
+		{"/items{?a,b}", 1, "/items?a={a}&b={b}"},
+		{"/items{?a}{&b,c}", 1, "/items?a={a}&b={b}&c={c}"},
+		{"{a,b}", 1, "{a},{b}"},
+		{"/x{.a,b}{/c,a}", 1, "/x.{a}.{b}/{c}/{a}"},
+		{"/x{;a,m}", 1, "/x;a={a};m={m}"},
+		{"{+a,b}{#b,c}", 2, "{+a},{+b}{#b},{+c}"},
+		{"/x{?a,b}{+a}", 2, "/x?a={a}&b={b}{+a}"},
+		{"/x{?a,b}", 3, "/x{?a,b}"},
+		{"/x{?a*}", 4, "/x{?a*}"},
+		{"/static", 1, "/static"},
+	}
+	for _, test := range tests {
+		template := MustParse(test.template)
+		downgraded, err := template.Downgrade(test.level)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.template, err)
+			continue
+		}
+		if downgraded.String() != test.expected {
+			t.Errorf("%s: Downgrade(%d) = %q, expected %q", test.template, test.level, downgraded.String(), test.expected)
+		}
+		if err := downgraded.ValidateLevel(test.level); err != nil {
+			t.Errorf("%s: %v", test.template, err)
+		}
+		expected, _ := template.Expand(values)
+		if actual, err := downgraded.Expand(values); err != nil || actual != expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.template, expected, actual, err)
+		}
+	}
+}
+
+func TestDowngradeDotted(t *testing.T) {
+	template, err := ParseDotted("/users{/.ID,.Name}{..Ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downgraded, err := template.Downgrade(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/users/{.ID}/{.Name}.{.Ext}"; downgraded.String() != expected {
+		t.Errorf("expected %q, got %q", expected, downgraded.String())
+	}
+	value := map[string]interface{}{"ID": 7, "Name": "ann", "Ext": "json"}
+	if actual, err := downgraded.Expand(value); err != nil || actual != "/users/7/ann.json" {
+		t.Errorf("expected %q, got %q, %v", "/users/7/ann.json", actual, err)
+	}
+}
+
+func TestDowngradeError(t *testing.T) {
+	tests := []struct {
+		template string
+		level    int
+	}{
+		{"{?list*}", 3},
+		{"{a:3}", 2},
+		{"{+path}", 1},
+		{"{#a,b}", 1},
+		{"{a}", 0},
+		{"{a}", 5},
+	}
+	for _, test := range tests {
+		if downgraded, err := MustParse(test.template).Downgrade(test.level); err == nil {
+			t.Errorf("%s: Downgrade(%d) = %q, expected error", test.template, test.level, downgraded)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package uri
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A ParseReason identifies the kind of problem reported by a ParseError.
+type ParseReason string
+
+const (
+	// ReasonUnexpectedClose is a "}" outside of an expression.
+	ReasonUnexpectedClose ParseReason = "unexpected-close"
+	// ReasonUnexpectedOpen is a "{" within an expression; expressions do
+	// not nest.
+	ReasonUnexpectedOpen ParseReason = "unexpected-open"
+	// ReasonUnclosed is an expression without a closing "}".
+	ReasonUnclosed ParseReason = "unclosed-expression"
+	// ReasonEmpty is an expression without variables, such as "{}" or "{a,}".
+	ReasonEmpty ParseReason = "empty-expression"
+	// ReasonInvalidName is a variable name that RFC 6570 does not allow.
+	ReasonInvalidName ParseReason = "invalid-name"
+	// ReasonInvalidPrefix is a prefix modifier that is not a number, or a
+	// variable with more than one.
+	ReasonInvalidPrefix ParseReason = "invalid-prefix"
+	// ReasonExplodePrefix is a variable with both an explode and a prefix
+	// modifier.
+	ReasonExplodePrefix ParseReason = "explode-and-prefix"
+)
+
+// A ParseError describes why a template string could not be parsed.
+type ParseError struct {
+	// Offset is the byte offset of the problem within the template.
+	Offset int
+	// Expr is the text of the offending expression without braces. It is
+	// empty for ReasonUnexpectedClose, which lies outside of expressions.
+	Expr   string
+	Reason ParseReason
+
+	msg string
+}
+
+func (e *ParseError) Error() string {
+	if e.Reason == ReasonUnexpectedClose {
+		return fmt.Sprintf("%s at offset %d", e.msg, e.Offset)
+	}
+	return fmt.Sprintf("%s in %s at offset %d", e.msg, strconv.Quote("{"+e.Expr+"}"), e.Offset)
+}
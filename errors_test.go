@@ -0,0 +1,43 @@
+package uri
+
+import (
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		raw    string
+		offset int
+		expr   string
+		reason ParseReason
+		msg    string
+	}{
+		{"/a}b", 2, "", ReasonUnexpectedClose, "unexpected } at offset 2"},
+		{"/{a}/b}", 6, "", ReasonUnexpectedClose, "unexpected } at offset 6"},
+		{"/{a}/{b", 5, "b", ReasonUnclosed, `unclosed expression in "{b}" at offset 5`},
+		{"/{a{b}}", 3, "a", ReasonUnexpectedOpen, `unexpected { in "{a}" at offset 3`},
+		{"{", 0, "", ReasonUnclosed, `unclosed expression in "{}" at offset 0`},
+		{"{{}", 1, "", ReasonUnexpectedOpen, `unexpected { in "{}" at offset 1`},
+		{"}", 0, "", ReasonUnexpectedClose, "unexpected } at offset 0"},
+		{"/x{}", 3, "", ReasonEmpty, `empty expression in "{}" at offset 3`},
+		{"/{a,}", 4, "a,", ReasonEmpty, `not a valid name:  in "{a,}" at offset 4`},
+		{"/{?a,b c}", 5, "?a,b c", ReasonInvalidName, `not a valid name: b c in "{?a,b c}" at offset 5`},
+		{"{a:x}", 3, "a:x", ReasonInvalidPrefix, `not a valid prefix: x in "{a:x}" at offset 3`},
+		{"{a:1:2}", 4, "a:1:2", ReasonInvalidPrefix, `multiple colons in same term in "{a:1:2}" at offset 4`},
+		{"{/a,b:3*}", 7, "/a,b:3*", ReasonExplodePrefix, `both explode and prefix modifers on same term in "{/a,b:3*}" at offset 7`},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.raw)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%s: want *ParseError, got %v", test.raw, err)
+			continue
+		}
+		if perr.Offset != test.offset || perr.Expr != test.expr || perr.Reason != test.reason {
+			t.Errorf("%s: want %d %q %s, got %d %q %s", test.raw, test.offset, test.expr, test.reason, perr.Offset, perr.Expr, perr.Reason)
+		}
+		if perr.Error() != test.msg {
+			t.Errorf("%s: want %q, got %q", test.raw, test.msg, perr.Error())
+		}
+	}
+}
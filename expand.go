@@ -1,7 +1,14 @@
 package uri
 
+import (
+	"container/list"
+	"sync"
+)
+
+// Expand parses path as a URI template and expands it with expansions.
+// Parsed templates are cached, see SetCacheSize.
 func Expand(path string, expansions map[string]interface{}) (string, error) {
-	template, err := Parse(path)
+	template, err := cache.parse(path)
 	if err != nil {
 		return "", err
 	}
@@ -11,3 +18,71 @@ func Expand(path string, expansions map[string]interface{}) (string, error) {
 	}
 	return template.Expand(values)
 }
+
+// DefaultCacheSize is the initial number of templates cached by Expand.
+const DefaultCacheSize = 256
+
+var cache = &templateCache{size: DefaultCacheSize}
+
+// SetCacheSize sets the number of parsed templates that Expand keeps for
+// reuse, evicting the least recently used ones first. A size of zero or
+// less disables the cache. It is safe for concurrent use.
+func SetCacheSize(size int) {
+	cache.resize(size)
+}
+
+type cacheEntry struct {
+	raw      string
+	template *Template
+}
+
+// templateCache is a concurrency-safe LRU cache of parsed templates.
+type templateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func (c *templateCache) parse(raw string) (*Template, error) {
+	c.mu.Lock()
+	if element, exists := c.entries[raw]; exists {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*cacheEntry).template, nil
+	}
+	c.mu.Unlock()
+	template, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return template, nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+	if _, exists := c.entries[raw]; !exists {
+		c.entries[raw] = c.order.PushFront(&cacheEntry{raw, template})
+		c.evict()
+	}
+	return template, nil
+}
+
+func (c *templateCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+}
+
+func (c *templateCache) evict() {
+	for c.order != nil && c.order.Len() > c.size && c.order.Len() > 0 {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).raw)
+	}
+}
@@ -0,0 +1,76 @@
+package uri
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPackageExpand(t *testing.T) {
+	defer SetCacheSize(DefaultCacheSize)
+	for _, size := range []int{DefaultCacheSize, 2, 0} {
+		SetCacheSize(size)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 4; j++ {
+				out, err := Expand(fmt.Sprintf("/%d/{id}", j), map[string]interface{}{"id": i})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := fmt.Sprintf("/%d/%d", j, i); want != out {
+					t.Errorf("want %s, got %s", want, out)
+				}
+			}
+		}
+		if cache.order != nil && cache.order.Len() > size && cache.order.Len() > 0 {
+			t.Errorf("cache holds %d templates, want at most %d", cache.order.Len(), size)
+		}
+	}
+	if _, err := Expand("/{id", nil); err == nil {
+		t.Errorf("want parse error")
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	c := &templateCache{size: 2}
+	a, _ := c.parse("/a")
+	if again, _ := c.parse("/a"); again != a {
+		t.Errorf("want cached template")
+	}
+	c.parse("/b")
+	c.parse("/a")
+	c.parse("/c")
+	if _, exists := c.entries["/b"]; exists {
+		t.Errorf("want least recently used template evicted")
+	}
+	if again, _ := c.parse("/a"); again != a {
+		t.Errorf("want recently used template kept")
+	}
+	c.resize(0)
+	if c.order.Len() != 0 {
+		t.Errorf("want cache emptied, holds %d", c.order.Len())
+	}
+	if again, _ := c.parse("/a"); again == a {
+		t.Errorf("want disabled cache to parse again")
+	}
+}
+
+func TestTemplateCacheConcurrent(t *testing.T) {
+	c := &templateCache{size: 8}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := c.parse(fmt.Sprintf("/%d{?q}", (i+j)%12)); err != nil {
+					t.Error(err)
+					return
+				}
+				if j%50 == 0 {
+					c.resize(4 + i%8)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package uri
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Vars expands a template from values of the type T, which is a struct, a
+// pointer to a struct, or a map with string keys. NewVars checks once that a
+// struct type provides every variable of the template, so a mismatch between
+// the two is reported when the Vars is built rather than as a parameter that
+// is silently left out of every expansion.
+type Vars[T any] struct {
+	template *Template
+}
+
+// NewVars returns a Vars for t. It fails if T is not a struct, a pointer to
+// a struct, or a map with string keys, or if T is a struct that has no field
+// for a variable of t.
+func NewVars[T any](t *Template) (*Vars[T], error) {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch {
+	case rt.Kind() == reflect.Map && rt.Key().Kind() == reflect.String:
+	case rt.Kind() == reflect.Struct:
+		if t.dotted {
+			break
+		}
+		names := make(map[string]bool)
+		typeNames(names, rt, "", 0)
+		var missing []string
+		for _, name := range t.Names() {
+			if !names[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s does not provide variables: %s", rt, strings.Join(missing, ", "))
+		}
+	default:
+		return nil, errors.New("expected map with string keys, struct, or pointer to struct.")
+	}
+	return &Vars[T]{template: t}, nil
+}
+
+// Template returns the template that v expands.
+func (v *Vars[T]) Template() *Template {
+	return v.template
+}
+
+// Expand expands the template with value like Template.Expand.
+func (v *Vars[T]) Expand(value T) (string, error) {
+	return v.template.Expand(value)
+}
+
+// ExpandValues expands t with v like Template.Expand, but takes v as a type
+// known at compile time, such as a struct declared for the template, rather
+// than as an interface{} or a map of interface{} values.
+func ExpandValues[T any](t *Template, v T) (string, error) {
+	return t.Expand(v)
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typeNames adds the variable names that a value of the struct type t
+// provides to names, including those of nested structs as described for
+// Template.Expand.
+func typeNames(names map[string]bool, t reflect.Type, prefix string, depth int) {
+	if depth >= defaultMaxDepth {
+		return
+	}
+	for _, f := range cachedFields(t, false) {
+		names[prefix+f.name] = true
+		ft := t.Field(f.index).Type
+		if ft.Implements(textMarshalerType) || ft.Implements(stringerType) || ft.Implements(errorType) {
+			continue
+		}
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			typeNames(names, ft, prefix+f.name+".", depth+1)
+		}
+	}
+}
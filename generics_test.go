@@ -0,0 +1,66 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type searchVars struct {
+	Q     string    `uri:"q"`
+	Page  int       `uri:"page,omitempty"`
+	Since time.Time `uri:"since,omitempty,layout=2006-01-02"`
+	User  struct {
+		Name string `uri:"name"`
+	} `uri:"user"`
+}
+
+func TestVars(t *testing.T) {
+	vars, err := NewVars[searchVars](MustParse("/search{?q,page,since,user.name}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value searchVars
+		out   string
+	}{
+		{searchVars{Q: "go"}, "/search?q=go&user.name="},
+		{searchVars{Q: "go", Page: 2, Since: time.Date(2017, 7, 13, 0, 0, 0, 0, time.UTC)}, "/search?q=go&page=2&since=2017-07-13&user.name="},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := vars.Expand(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestNewVarsErrors(t *testing.T) {
+	if _, err := NewVars[searchVars](MustParse("/search{?q,limit,user.id}")); err == nil || err.Error() != "uri.searchVars does not provide variables: limit, user.id" {
+		t.Errorf("want missing variables error, got %v", err)
+	}
+	if _, err := NewVars[int](MustParse("/{id}")); err == nil {
+		t.Error("want error for int")
+	}
+	if _, err := NewVars[*searchVars](MustParse("/{q}")); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewVars[map[string]string](MustParse("/{id}")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpandValues(t *testing.T) {
+	out, err := ExpandValues(MustParse("/users/{id}"), map[string]int{"id": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "/users/42" {
+		t.Errorf("want /users/42, got %s", out)
+	}
+}
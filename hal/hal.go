@@ -0,0 +1,193 @@
+// Package hal models the links of HAL documents, as described by
+// draft-kelly-json-hal, and expands templated links with package uri:
+//
+//	links, err := hal.ParseLinks(body)
+//	if err != nil {
+//		return err
+//	}
+//	next, err := links.Expand("ea:find", map[string]interface{}{"id": 42})
+//
+// A relation of the _links object holds either a single link object or an
+// array of them; Links holds both as a list. Compact relations such as
+// "ea:find" are resolved with the "curies" relation, so that a link can be
+// found by its compact relation as well as by the URI it stands for.
+package hal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cognicraft/uri"
+)
+
+// A Link is a HAL link object.
+type Link struct {
+	Href string `json:"href"`
+	// Templated is set if Href is a URI template.
+	Templated   bool   `json:"templated,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Deprecation string `json:"deprecation,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Hreflang    string `json:"hreflang,omitempty"`
+}
+
+var errNotTemplated = errors.New("link is not templated")
+
+// Template parses the Href of a templated link. It returns an error if the
+// link is not templated.
+func (l Link) Template() (*uri.Template, error) {
+	if !l.Templated {
+		return nil, errNotTemplated
+	}
+	return uri.Parse(l.Href)
+}
+
+// Expand returns the target of the link: the expansion of Href with value
+// if the link is templated, as by uri.Template.Expand, and Href itself
+// otherwise.
+func (l Link) Expand(value interface{}) (string, error) {
+	if !l.Templated {
+		return l.Href, nil
+	}
+	t, err := l.Template()
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(value)
+}
+
+// Curies is the relation of the links that define compact relations.
+const Curies = "curies"
+
+// Links is a HAL _links object: the links of a resource by relation.
+type Links map[string][]Link
+
+// UnmarshalJSON decodes a _links object, whose relations hold a link object
+// or an array of link objects.
+func (l *Links) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	links := make(Links, len(raw))
+	for rel, r := range raw {
+		if trimmed := strings.TrimSpace(string(r)); strings.HasPrefix(trimmed, "[") {
+			var list []Link
+			if err := json.Unmarshal(r, &list); err != nil {
+				return fmt.Errorf("%s: %v", rel, err)
+			}
+			links[rel] = list
+			continue
+		}
+		var link Link
+		if err := json.Unmarshal(r, &link); err != nil {
+			return fmt.Errorf("%s: %v", rel, err)
+		}
+		links[rel] = []Link{link}
+	}
+	*l = links
+	return nil
+}
+
+// MarshalJSON encodes a _links object. Relations with a single link are
+// written as a link object, except for curies, which HAL requires to be an
+// array.
+func (l Links) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{}, len(l))
+	for rel, list := range l {
+		if len(list) == 1 && rel != Curies {
+			raw[rel] = list[0]
+		} else {
+			raw[rel] = list
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// ParseLinks returns the _links object of a HAL resource document.
+func ParseLinks(doc []byte) (Links, error) {
+	var resource struct {
+		Links Links `json:"_links"`
+	}
+	if err := json.Unmarshal(doc, &resource); err != nil {
+		return nil, err
+	}
+	return resource.Links, nil
+}
+
+// Resolve returns the relation URI that the compact relation rel, such as
+// "ea:find", stands for: the expansion of the href of the curie named "ea"
+// with rel set to "find". Relations without a matching curie are returned
+// unchanged.
+func (l Links) Resolve(rel string) string {
+	i := strings.IndexByte(rel, ':')
+	if i < 0 {
+		return rel
+	}
+	for _, curie := range l[Curies] {
+		if curie.Name != rel[:i] {
+			continue
+		}
+		if resolved, err := curie.Expand(map[string]interface{}{"rel": rel[i+1:]}); err == nil {
+			return resolved
+		}
+	}
+	return rel
+}
+
+// Find returns the links of the relation rel. If there are none under rel
+// itself, it returns those of a relation that resolves to the same URI,
+// such that "ea:find" and "http://example.com/docs/rels/find" find each
+// other's links.
+func (l Links) Find(rel string) []Link {
+	if list, exists := l[rel]; exists {
+		return list
+	}
+	resolved := l.Resolve(rel)
+	rels := make([]string, 0, len(l))
+	for r := range l {
+		rels = append(rels, r)
+	}
+	sort.Strings(rels)
+	for _, r := range rels {
+		if r != Curies && l.Resolve(r) == resolved {
+			return l[r]
+		}
+	}
+	return nil
+}
+
+// Link returns the first link of the relation rel, as found by Find.
+func (l Links) Link(rel string) (Link, bool) {
+	list := l.Find(rel)
+	if len(list) == 0 {
+		return Link{}, false
+	}
+	return list[0], true
+}
+
+// Named returns the link of the relation rel with the given name, which
+// tells apart the links of a relation that holds several.
+func (l Links) Named(rel, name string) (Link, bool) {
+	for _, link := range l.Find(rel) {
+		if link.Name == name {
+			return link, true
+		}
+	}
+	return Link{}, false
+}
+
+// Expand expands the first link of the relation rel with value, as
+// described for Link.Expand.
+func (l Links) Expand(rel string, value interface{}) (string, error) {
+	link, ok := l.Link(rel)
+	if !ok {
+		return "", fmt.Errorf("no link with relation %s", rel)
+	}
+	return link.Expand(value)
+}
@@ -0,0 +1,115 @@
+package hal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const orders = `{
+	"_links": {
+		"self": {"href": "/orders"},
+		"curies": [{"name": "ea", "href": "http://example.com/docs/rels/{rel}", "templated": true}],
+		"next": {"href": "/orders?page=2"},
+		"ea:find": {"href": "/orders{?id}", "templated": true},
+		"ea:admin": [
+			{"href": "/admins/2", "title": "Fred", "name": "fred"},
+			{"href": "/admins/5", "title": "Kate", "name": "kate"}
+		]
+	},
+	"currentlyProcessing": 14
+}`
+
+func TestParseLinks(t *testing.T) {
+	links, err := ParseLinks([]byte(orders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rel      string
+		values   map[string]interface{}
+		expected string
+	}{
+		{"self", nil, "/orders"},
+		{"next", map[string]interface{}{"page": 3}, "/orders?page=2"},
+		{"ea:find", map[string]interface{}{"id": 42}, "/orders?id=42"},
+		{"ea:find", nil, "/orders"},
+		{"http://example.com/docs/rels/find", map[string]interface{}{"id": "a b"}, "/orders?id=a%20b"},
+		{"ea:admin", nil, "/admins/2"},
+	}
+	for _, test := range tests {
+		actual, err := links.Expand(test.rel, test.values)
+		if err != nil || actual != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.rel, test.expected, actual, err)
+		}
+	}
+	if _, err := links.Expand("missing", nil); err == nil {
+		t.Errorf("expected an error for a missing relation")
+	}
+	if admins := links.Find("http://example.com/docs/rels/admin"); len(admins) != 2 {
+		t.Errorf("expected 2 admin links, got %v", admins)
+	}
+	if kate, ok := links.Named("ea:admin", "kate"); !ok || kate.Href != "/admins/5" {
+		t.Errorf("expected kate, got %v, %v", kate, ok)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	links := Links{Curies: {{Name: "ea", Href: "http://example.com/docs/rels/{rel}", Templated: true}}}
+	for rel, expected := range map[string]string{
+		"ea:find":                  "http://example.com/docs/rels/find",
+		"xx:find":                  "xx:find",
+		"self":                     "self",
+		"http://example.com/other": "http://example.com/other",
+	} {
+		if actual := links.Resolve(rel); actual != expected {
+			t.Errorf("%s: expected %q, got %q", rel, expected, actual)
+		}
+	}
+}
+
+func TestLinkTemplate(t *testing.T) {
+	template, err := Link{Href: "/orders{?id}", Templated: true}.Template()
+	if err != nil || template.String() != "/orders{?id}" {
+		t.Errorf("expected template, got %v, %v", template, err)
+	}
+	if _, err := (Link{Href: "/orders"}).Template(); err == nil {
+		t.Errorf("expected an error for a link that is not templated")
+	}
+	if _, err := (Link{Href: "/orders{?id", Templated: true}).Expand(nil); err == nil {
+		t.Errorf("expected an error for an invalid template")
+	}
+	if actual, err := (Link{Href: "/a{b}"}).Expand(map[string]interface{}{"b": "x"}); err != nil || actual != "/a{b}" {
+		t.Errorf("expected the href of a link that is not templated, got %q, %v", actual, err)
+	}
+}
+
+func TestLinksJSON(t *testing.T) {
+	links, err := ParseLinks([]byte(orders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for rel, array := range map[string]bool{"self": false, "curies": true, "ea:admin": true} {
+		if isArray := raw[rel][0] == '['; isArray != array {
+			t.Errorf("%s: expected array %v, got %s", rel, array, raw[rel])
+		}
+	}
+	var decoded Links
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, links) {
+		t.Errorf("expected %v, got %v", links, decoded)
+	}
+	if err := json.Unmarshal([]byte(`{"self": "/orders"}`), &decoded); err == nil {
+		t.Errorf("expected an error for an invalid link")
+	}
+}
@@ -0,0 +1,262 @@
+package uri
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// HostToASCII converts the labels of a Unicode host name to their A-label
+// form, "xn--" followed by the Punycode encoding of RFC 3492, and lowercases
+// the others: "Bücher.example" becomes "xn--bcher-kva.example". It performs
+// neither the Unicode mapping nor the validation of IDNA, so host should
+// already be in the form to be registered, such as lowercase NFC.
+func HostToASCII(host string) (string, error) {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		if !utf8.ValidString(label) {
+			return "", errors.New("invalid UTF-8 in host: " + host)
+		}
+		labels[i] = "xn--" + punyEncode(label)
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// HostToUnicode converts the A-labels of a host name back to Unicode, so
+// that "xn--bcher-kva.example" becomes "bücher.example".
+func HostToUnicode(host string) (string, error) {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if len(label) < 4 || !strings.EqualFold(label[:4], "xn--") {
+			continue
+		}
+		decoded, err := punyDecode(label[4:])
+		if err != nil {
+			return "", err
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters, RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyEncode encodes s with the Punycode algorithm of RFC 3492 section 6.3.
+func punyEncode(s string) string {
+	runes := []rune(strings.ToLower(s))
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for handled < len(runes) {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+// punyDecode decodes s with the Punycode algorithm of RFC 3492 section 6.2.
+func punyDecode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		for _, c := range s[:i] {
+			if c >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+			output = append(output, c)
+		}
+		pos = i + 1
+	}
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos < len(s) {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(s) {
+				return "", errPunycode
+			}
+			c := s[pos]
+			pos++
+			var digit int
+			switch {
+			case 'a' <= c && c <= 'z':
+				digit = int(c - 'a')
+			case 'A' <= c && c <= 'Z':
+				digit = int(c - 'A')
+			case '0' <= c && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", errPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punyBase - t
+			if i < 0 || w < 0 {
+				return "", errPunycode
+			}
+		}
+		bias = punyAdapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > utf8.MaxRune {
+			return "", errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
+
+// markHosts sets the host flag of the expressions that lie within the host
+// of the template, such as "{host}" in "https://{host}/". The host starts
+// after the "//" that follows the scheme or starts the template, and ends at
+// the port, path, query or fragment. Expressions before an "@" belong to
+// the user information instead.
+func (t *Template) markHosts() {
+	inHost, seen := false, false
+	for i := range t.parts {
+		p := &t.parts[i]
+		if p.terms != nil {
+			switch p.op {
+			case 0, '+', '.':
+				p.host = inHost
+			default:
+				inHost = false
+			}
+			continue
+		}
+		for j := 0; j < len(p.raw); j++ {
+			switch c := p.raw[j]; {
+			case !seen && strings.HasPrefix(p.raw[j:], "//") && (i == 0 && j == 0 || j > 0 && p.raw[j-1] == ':'):
+				inHost, seen = true, true
+				j++
+			case c == '@' && inHost:
+				for k := range t.parts[:i] {
+					t.parts[k].host = false
+				}
+			case c == '/' || c == '?' || c == '#' || c == ':':
+				inHost = false
+			}
+		}
+	}
+}
+
+// mapHost returns uri with its host replaced by f(host), or uri unchanged if
+// it has no authority or f fails.
+func mapHost(uri string, f func(string) (string, error)) string {
+	i := strings.Index(uri, "//")
+	if i < 0 || strings.ContainsAny(uri[:i], "/?#") {
+		return uri
+	}
+	start := i + 2
+	end := start + strings.IndexAny(uri[start:]+"/", "/?#")
+	if at := strings.LastIndexByte(uri[start:end], '@'); at >= 0 {
+		start += at + 1
+	}
+	if colon := strings.LastIndexByte(uri[start:end], ':'); colon >= 0 && !strings.Contains(uri[start+colon:end], "]") {
+		end = start + colon
+	}
+	host, err := f(uri[start:end])
+	if err != nil {
+		return uri
+	}
+	return uri[:start] + host + uri[end:]
+}
@@ -0,0 +1,70 @@
+package uri
+
+import "testing"
+
+func TestHostToASCII(t *testing.T) {
+	tests := []struct {
+		host    string
+		ascii   string
+		unicode string
+	}{
+		{"example.com", "example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example", "bücher.example"},
+		{"münchen.de", "xn--mnchen-3ya.de", "münchen.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah", "例え.テスト"},
+		{"ليهمابتكلموشعربي؟", "xn--egbpdaj6bu4bxfgehfvwxn", "ليهمابتكلموشعربي؟"},
+		{"Exa-mple.COM", "exa-mple.com", "exa-mple.com"},
+	}
+	for _, test := range tests {
+		ascii, err := HostToASCII(test.host)
+		if err != nil || ascii != test.ascii {
+			t.Errorf("HostToASCII(%q) = %q, %v, expected %q", test.host, ascii, err, test.ascii)
+		}
+		unicode, err := HostToUnicode(ascii)
+		if err != nil || unicode != test.unicode {
+			t.Errorf("HostToUnicode(%q) = %q, %v, expected %q", ascii, unicode, err, test.unicode)
+		}
+	}
+	if _, err := HostToUnicode("xn--a-!.example"); err == nil {
+		t.Errorf("HostToUnicode accepted invalid punycode")
+	}
+}
+
+func TestExpandIDNA(t *testing.T) {
+	values := map[string]interface{}{"host": "bücher.example", "user": "jürgen", "q": "bücher"}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"https://{host}/{q}", "https://xn--bcher-kva.example/b%C3%BCcher"},
+		{"//{host}{/q}", "//xn--bcher-kva.example/b%C3%BCcher"},
+		{"https://{user}@{host}:8080", "https://j%C3%BCrgen@xn--bcher-kva.example:8080"},
+		{"https://www.{host}", "https://www.xn--bcher-kva.example"},
+		{"/{q}//{host}", "/b%C3%BCcher//b%C3%BCcher.example"},
+	}
+	for _, test := range tests {
+		actual, err := MustParse(test.template).ExpandWithOpts(values, ExpandOpts{IDNA: true})
+		if err != nil || actual != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.template, test.expected, actual, err)
+		}
+	}
+}
+
+func TestMatchIDNA(t *testing.T) {
+	tests := []struct {
+		template string
+		uri      string
+		host     string
+	}{
+		{"https://{host}/", "https://xn--bcher-kva.example/", "xn--bcher-kva.example"},
+		{"https://bücher.example/{host}", "https://xn--bcher-kva.example/a", "a"},
+		{"https://xn--bcher-kva.example/{host}", "https://bücher.example/a", "a"},
+		{"https://user@xn--bcher-kva.example:8080/{host}", "https://user@bücher.example:8080/a", "a"},
+	}
+	for _, test := range tests {
+		values, ok := MustParse(test.template).Match(test.uri)
+		if !ok || values["host"] != test.host {
+			t.Errorf("%s: Match(%q) = %v, %v, expected host %q", test.template, test.uri, values, ok, test.host)
+		}
+	}
+}
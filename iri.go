@@ -0,0 +1,67 @@
+package uri
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// isUcschar reports whether RFC 3987 allows r in IRIs outside of the query,
+// which additionally allows private use characters.
+func isUcschar(r rune) bool {
+	switch {
+	case 0xA0 <= r && r <= 0xD7FF, 0xF900 <= r && r <= 0xFDCF, 0xFDF0 <= r && r <= 0xFFEF:
+		return true
+	case 0xE0000 <= r && r < 0xE1000:
+		return false
+	case 0x10000 <= r && r <= 0xEFFFD:
+		// planes 1 to 14 without their last two code points
+		return r&0xFFFF <= 0xFFFD
+	}
+	return false
+}
+
+// ToURI converts an IRI to a URI as described by RFC 3987 section 3.1, by
+// percent-encoding the UTF-8 bytes of every non-ASCII character. All other
+// characters, including existing percent-encodings, are kept.
+func ToURI(iri string) string {
+	var b strings.Builder
+	for i := 0; i < len(iri); i++ {
+		if c := iri[i]; c < utf8.RuneSelf {
+			b.WriteByte(c)
+		} else {
+			b.Write(pctEncode([]byte{c}))
+		}
+	}
+	return b.String()
+}
+
+// ToIRI converts a URI to an IRI for display as described by RFC 3987
+// section 3.2, by decoding the percent-encoded UTF-8 sequences of characters
+// that IRIs allow. All other percent-encodings are kept.
+func ToIRI(uri string) string {
+	var b strings.Builder
+	for i := 0; i < len(uri); {
+		if !isTriplet(uri, i) {
+			b.WriteByte(uri[i])
+			i++
+			continue
+		}
+		var octets []byte
+		j := i
+		for isTriplet(uri, j) && len(octets) < utf8.UTFMax {
+			octets = append(octets, unhex(uri[j+1])<<4|unhex(uri[j+2]))
+			j += 3
+			if utf8.FullRune(octets) {
+				break
+			}
+		}
+		if r, size := utf8.DecodeRune(octets); size == len(octets) && size > 1 && isUcschar(r) {
+			b.Write(octets)
+			i = j
+			continue
+		}
+		b.WriteString(uri[i : i+3])
+		i += 3
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpandIRI(t *testing.T) {
+	values := map[string]interface{}{"name": "Dürst 日本", "path": "/résumé/ä b", "bom": "\u0085x", "tag": "\U000E0041"}
+	tests := []struct {
+		raw  string
+		opts ExpandOpts
+		out  string
+	}{
+		{"https://例え.jp/{name}", ExpandOpts{IRI: true}, "https://例え.jp/Dürst%20日本"},
+		{"https://例え.jp/{name}", ExpandOpts{}, "https://例え.jp/D%C3%BCrst%20%E6%97%A5%E6%9C%AC"},
+		{"{+path}", ExpandOpts{IRI: true}, "/résumé/ä%20b"},
+		{"{?name}", ExpandOpts{IRI: true, LowerHex: true}, "?name=Dürst%20日本"},
+		{"{name}", ExpandOpts{IRI: true, Escaping: OAuth1}, "D%C3%BCrst%20%E6%97%A5%E6%9C%AC"},
+		{"{bom}", ExpandOpts{IRI: true}, "%C2%85x"},
+		{"{tag}", ExpandOpts{IRI: true}, "%F3%A0%81%81"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).ExpandWithOpts(values, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestToURIAndIRI(t *testing.T) {
+	tests := []struct {
+		iri, uri string
+	}{
+		{"https://例え.jp/Dürst%20日本", "https://%E4%BE%8B%E3%81%88.jp/D%C3%BCrst%20%E6%97%A5%E6%9C%AC"},
+		{"http://example.com/%2F?q=%41", "http://example.com/%2F?q=%41"},
+		{"/\U0001F600", "/%F0%9F%98%80"},
+	}
+	for _, test := range tests {
+		if uri := ToURI(test.iri); uri != test.uri {
+			t.Errorf("ToURI(%s): want %s, got %s", test.iri, test.uri, uri)
+		}
+		if iri := ToIRI(test.uri); iri != test.iri {
+			t.Errorf("ToIRI(%s): want %s, got %s", test.uri, test.iri, iri)
+		}
+	}
+	// invalid or disallowed sequences stay encoded
+	for _, uri := range []string{"/%C3", "/%C3%28", "/%C2%85", "/%FF%FE", "/%E6%97"} {
+		if iri := ToIRI(uri); iri != uri {
+			t.Errorf("ToIRI(%s): want unchanged, got %s", uri, iri)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package uri
+
+import "strings"
+
+// Join parses each of parts as a template and joins them in order with
+// Template.Join, so that Join("https://api.example.com{/version}", "users",
+// "{id}{?fields}") is the template
+// "https://api.example.com{/version}/users/{id}{?fields}".
+func Join(parts ...string) (*Template, error) {
+	joined, err := Parse("")
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		t, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		joined = joined.Join(t)
+	}
+	return joined, nil
+}
+
+// Join returns a new template that expands t followed by other, with exactly
+// one "/" between them: a "/" is added if neither side provides one, and a
+// duplicate is dropped, such as the trailing "/" of t before a "/" or "{/...}"
+// at the start of other. No "/" is added before other if it starts with a
+// query or fragment, or with an expression whose operator is other than "+",
+// such as "{?q}" or "{.ext}". The expressions of t and other are kept as
+// they were parsed, and the result keeps the truncate handler and
+// preprocessing function of t, and the constraints of both.
+func (t *Template) Join(other *Template) *Template {
+	last := len(t.parts) - 1
+	left, right := t.parts[last].raw, other.parts[0].raw
+	switch {
+	case len(t.raw) == 0 || len(other.raw) == 0:
+		left += right
+	case strings.HasSuffix(left, "/") && strings.HasPrefix(right, "/"):
+		left += right[1:]
+	case strings.HasSuffix(left, "/") && len(right) == 0 && other.parts[1].op == '/':
+		left = left[:len(left)-1]
+	case strings.HasSuffix(left, "/") || startsDelimited(other):
+		left += right
+	default:
+		left += "/" + right
+	}
+	joined := &Template{
+		raw:             t.raw[:len(t.raw)-len(t.parts[last].raw)] + left + other.raw[len(right):],
+		parts:           make([]templatePart, 0, len(t.parts)+len(other.parts)-1),
+		dotted:          t.dotted,
+		truncateHandler: t.truncateHandler,
+		preProcess:      t.preProcess,
+		constraints:     t.constraints,
+	}
+	if len(other.constraints) > 0 {
+		joined.constraints = make(map[string]Constraint, len(t.constraints)+len(other.constraints))
+		for _, constraints := range []map[string]Constraint{t.constraints, other.constraints} {
+			for k, v := range constraints {
+				joined.constraints[k] = v
+			}
+		}
+	}
+	joined.parts = append(joined.parts, t.parts[:last]...)
+	joined.parts = append(joined.parts, templatePart{raw: left})
+	joined.parts = append(joined.parts, other.parts[1:]...)
+	joined.static = len(joined.parts) == 1
+	joined.markHosts()
+	return joined
+}
+
+// startsDelimited reports whether the expansion of t starts with its own
+// delimiter, so that no "/" should be placed before it.
+func startsDelimited(t *Template) bool {
+	if first := t.parts[0].raw; len(first) > 0 {
+		return first[0] == '/' || first[0] == '?' || first[0] == '#'
+	}
+	op := t.parts[1].op
+	return op != 0 && op != '+'
+}
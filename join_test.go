@@ -0,0 +1,51 @@
+package uri
+
+import "testing"
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		parts    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"https://api.example.com{/version}", "users", "{id}{?fields}"}, "https://api.example.com{/version}/users/{id}{?fields}"},
+		{[]string{"https://api.example.com/", "/users/"}, "https://api.example.com/users/"},
+		{[]string{"https://api.example.com", "users"}, "https://api.example.com/users"},
+		{[]string{"https://api.example.com/", "{/id}"}, "https://api.example.com{/id}"},
+		{[]string{"/users", "{/id}"}, "/users{/id}"},
+		{[]string{"/users/", "{id}"}, "/users/{id}"},
+		{[]string{"/users", "{+path}"}, "/users/{+path}"},
+		{[]string{"/users", "{?q}", "{&p}"}, "/users{?q}{&p}"},
+		{[]string{"/users", "?q=1"}, "/users?q=1"},
+		{[]string{"/files/{name}", "{.ext}"}, "/files/{name}{.ext}"},
+		{[]string{"", "users", ""}, "users"},
+	}
+	for _, test := range tests {
+		joined, err := Join(test.parts...)
+		if err != nil {
+			t.Errorf("Join(%q): %v", test.parts, err)
+			continue
+		}
+		if joined.String() != test.expected {
+			t.Errorf("Join(%q) = %q, expected %q", test.parts, joined.String(), test.expected)
+		}
+		if !joined.Equal(MustParse(test.expected)) {
+			t.Errorf("Join(%q) parsed differently from %q: %#v", test.parts, test.expected, joined)
+		}
+	}
+	if _, err := Join("/users", "{id"); err == nil {
+		t.Errorf("Join accepted an invalid part")
+	}
+}
+
+func TestTemplateJoin(t *testing.T) {
+	base := MustParse("https://{host}{/version}")
+	joined := base.Join(MustParse("users{/id}"))
+	actual, err := joined.ExpandWithOpts(map[string]interface{}{"host": "bücher.example", "version": "v1", "id": 42}, ExpandOpts{IDNA: true})
+	if expected := "https://xn--bcher-kva.example/v1/users/42"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+	if base.String() != "https://{host}{/version}" {
+		t.Errorf("Join modified its receiver: %q", base.String())
+	}
+}
@@ -0,0 +1,67 @@
+package uri
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// MarshalJSON encodes the template as a JSON string holding its text. A
+// template parsed by ParseDotted is encoded as an object that also records
+// its mode, {"template":"/u/{.Name}","dotted":true}, so that it is decoded
+// as a dotted template again.
+func (t *Template) MarshalJSON() ([]byte, error) {
+	if t.dotted {
+		return json.Marshal(struct {
+			Template string `json:"template"`
+			Dotted   bool   `json:"dotted"`
+		}{t.raw, true})
+	}
+	return json.Marshal(t.raw)
+}
+
+// UnmarshalJSON parses a template from a JSON string, or from an object as
+// produced by Structured or by MarshalJSON for dotted templates.
+func (t *Template) UnmarshalJSON(data []byte) error {
+	var raw string
+	dotted := false
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var s struct {
+			Template *string `json:"template"`
+			Dotted   bool    `json:"dotted"`
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s.Template == nil {
+			return errors.New("missing template in JSON object")
+		}
+		raw, dotted = *s.Template, s.Dotted
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed, err := parse(raw, dotted)
+	if err != nil {
+		return err
+	}
+	*t = *parsed
+	return nil
+}
+
+// Structured wraps a Template so that it is encoded as a JSON object holding
+// its parsed segments as well as its text, so that tools can inspect it
+// without parsing it again:
+//
+//	{"template":"/users{/id}","segments":[{"literal":"/users"},{"expression":{"operator":"/","vars":[{"name":"id"}]}}]}
+type Structured struct {
+	*Template
+}
+
+// MarshalJSON encodes the template as an object with its text and segments.
+func (s Structured) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Template string    `json:"template"`
+		Dotted   bool      `json:"dotted,omitempty"`
+		Segments []Segment `json:"segments"`
+	}{s.raw, s.dotted, s.Segments()})
+}
@@ -0,0 +1,81 @@
+package uri
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemplateJSON(t *testing.T) {
+	type link struct {
+		Href *Template `json:"href"`
+	}
+	var l link
+	if err := json.Unmarshal([]byte(`{"href":"/users{/id}{?q}"}`), &l); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := l.Href.Expand(map[string]interface{}{"id": 1, "q": "x"}); out != "/users/1?q=x" {
+		t.Errorf("want /users/1?q=x, got %s", out)
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"href":"/users{/id}{?q}"}`; string(data) != want {
+		t.Errorf("want %s, got %s", want, data)
+	}
+	if err := json.Unmarshal([]byte(`{"href":"/users{/id"}`), &l); err == nil {
+		t.Errorf("want error for an invalid template")
+	}
+}
+
+func TestDottedTemplateJSON(t *testing.T) {
+	template, err := ParseDotted("/u/{.Name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"template":"/u/{.Name}","dotted":true}`; string(data) != want {
+		t.Errorf("want %s, got %s", want, data)
+	}
+	structured, err := json.Marshal(Structured{template})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{data, structured} {
+		var parsed Template
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if !parsed.Equal(template) {
+			t.Errorf("%s: want a dotted template", data)
+		}
+		if out, err := parsed.Expand(struct{ Name string }{"bob"}); err != nil || out != "/u/bob" {
+			t.Errorf("%s: want /u/bob, got %s, %v", data, out, err)
+		}
+	}
+}
+
+func TestStructuredJSON(t *testing.T) {
+	template := MustParse("/users{/id}{?q,limit:3,tags*}")
+	data, err := json.Marshal(Structured{template})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"template":"/users{/id}{?q,limit:3,tags*}","segments":[{"literal":"/users"},{"expression":{"operator":"/","vars":[{"name":"id"}]}},{"expression":{"operator":"?","vars":[{"name":"q"},{"name":"limit","prefix":3},{"name":"tags","explode":true}]}}]}`
+	if string(data) != want {
+		t.Errorf("want %s, got %s", want, data)
+	}
+	var parsed Template
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.raw != template.raw {
+		t.Errorf("want %s, got %s", template.raw, parsed.raw)
+	}
+	if err := json.Unmarshal([]byte(`{"segments":[]}`), &parsed); err == nil {
+		t.Errorf("want error for an object without template")
+	}
+}
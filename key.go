@@ -0,0 +1,44 @@
+package uri
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ExpandWithIdempotencyKey expands a URI template like Expand and also
+// returns a key suitable for idempotent requests: the hex-encoded SHA-256
+// hash of the expanded URL and of the values of the template's variables.
+// The same template and values always produce the same key. Values are
+// hashed by content, with pointers followed and map entries sorted, so an
+// error is returned for values whose content cannot be hashed, such as
+// io.Reader values, channels, functions including Lazy values, and
+// templates.
+func (t *Template) ExpandWithIdempotencyKey(value interface{}) (url string, key string, err error) {
+	values, err := t.values(value, &ExpandOpts{})
+	if err != nil {
+		return "", "", err
+	}
+	names := t.Names()
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		if v, exists := values[name]; exists {
+			fmt.Fprintf(&canonical, "\n%s=", name)
+			if !writeCanonical(&canonical, v, 0) {
+				return "", "", errors.New("cannot hash the value of " + name)
+			}
+		}
+	}
+	url, err = t.Expand(value)
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	io.WriteString(h, url)
+	io.WriteString(h, canonical.String())
+	return url, fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,48 @@
+package uri
+
+import "fmt"
+
+// A Lazy value is computed only if the template references its variable,
+// when the expression of the variable is expanded. A value of type
+// func() (interface{}, error) is treated the same way. The result is
+// expanded in place of the function and computed at most once per
+// expansion; a nil result leaves the variable undefined, so that its
+// default applies, and an error ends the expansion.
+//
+//	uri.MustParse("/items{?q}").Expand(map[string]interface{}{
+//		"q":     "shoes",
+//		"token": uri.Lazy(fetchToken), // not called
+//	})
+type Lazy func() (interface{}, error)
+
+// A lazyKey identifies the result of a lazy value or of a lazy default.
+type lazyKey struct {
+	name      string
+	isDefault bool
+}
+
+// resolveLazy returns the result of value if it is lazy, and value itself
+// otherwise.
+func (e *expander) resolveLazy(key lazyKey, value interface{}) (interface{}, error) {
+	var f Lazy
+	switch v := value.(type) {
+	case Lazy:
+		f = v
+	case func() (interface{}, error):
+		f = v
+	default:
+		return value, nil
+	}
+	if v, computed := e.lazy[key]; computed {
+		return v, nil
+	}
+	v, err := f()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key.name, err)
+	}
+	if e.lazy == nil {
+		e.lazy = make(map[lazyKey]interface{})
+	}
+	e.lazy[key] = v
+	return v, nil
+}
@@ -0,0 +1,69 @@
+package uri
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	calls := make(map[string]int)
+	lazy := func(name string, v interface{}) Lazy {
+		return func() (interface{}, error) {
+			calls[name]++
+			return v, nil
+		}
+	}
+	values := map[string]interface{}{
+		"id":     lazy("id", 42),
+		"q":      func() (interface{}, error) { calls["q"]++; return "a b", nil },
+		"tags":   lazy("tags", []string{"x", "y"}),
+		"none":   lazy("none", nil),
+		"unused": lazy("unused", "never"),
+	}
+	actual, err := MustParse("/items/{id}{?q,tags,none}{&id}").Expand(values)
+	if expected := "/items/42?q=a%20b&tags=x,y&id=42"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+	for name, expected := range map[string]int{"id": 1, "q": 1, "tags": 1, "none": 1, "unused": 0} {
+		if calls[name] != expected {
+			t.Errorf("%s: expected %d calls, got %d", name, expected, calls[name])
+		}
+	}
+}
+
+func TestLazyError(t *testing.T) {
+	errExpensive := errors.New("unavailable")
+	values := map[string]interface{}{
+		"token": Lazy(func() (interface{}, error) { return nil, errExpensive }),
+	}
+	if _, err := MustParse("/a{?token}").Expand(values); err == nil {
+		t.Errorf("expected error")
+	}
+	if actual, err := MustParse("/a").Expand(values); err != nil || actual != "/a" {
+		t.Errorf("expected %q, got %q, %v", "/a", actual, err)
+	}
+}
+
+func TestLazyDefaults(t *testing.T) {
+	none := Lazy(func() (interface{}, error) { return nil, nil })
+	tests := []struct {
+		values   map[string]interface{}
+		defaults map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"page": none}, map[string]interface{}{"page": 1}, "/items?page=1"},
+		{map[string]interface{}{"page": none}, map[string]interface{}{"page": none}, "/items"},
+		{map[string]interface{}{}, map[string]interface{}{"page": Lazy(func() (interface{}, error) { return 2, nil })}, "/items?page=2"},
+		{map[string]interface{}{"page": none}, map[string]interface{}{"page": Lazy(func() (interface{}, error) { return 3, nil })}, "/items?page=3"},
+	}
+	for _, test := range tests {
+		actual, err := MustParse("/items{?page}").ExpandWith(test.values, WithDefaults(test.defaults))
+		if err != nil || actual != test.expected {
+			t.Errorf("expected %q, got %q, %v", test.expected, actual, err)
+		}
+	}
+	values := map[string]interface{}{"page": none}
+	if _, err := MustParse("/items{?page}").ExpandWith(values, WithDefaults(map[string]interface{}{"page": 1}), WithStrict()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package uri
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Match matches uri against the template and returns the percent-decoded
+// values of the variables it defines, which makes it possible to use the
+// same template to build URIs and to route them.
+//
+// Each expression matches according to its operator: "/", ".", ";", "?",
+// "&" and "#" expressions must begin with their operator character, and are
+// skipped if it is absent. Variables of expressions that are absent from uri
+// are left out of the result. List values, such as those of exploded
+// variables, are returned joined by commas.
+//
+// Parameters matched by query expressions ("?" and "&") may appear in any
+// order. Parameters that are not variables of the expression are ignored,
+// unless the expression has an exploded variable, in which case they are
+// returned under their own names.
+//
+// The host of uri may be given in either its Unicode or its A-label form
+// ("bücher.example" or "xn--bcher-kva.example"); if uri does not match as
+// is, it is matched again with its host converted to the other form.
+//
+// Values that violate the constraint of their variable, as set by
+// Constrain, do not match.
+func (t *Template) Match(uri string) (map[string]string, bool) {
+	values, ok := t.matchUnchecked(uri)
+	if !ok || t.checkMatch(values) != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// matchUnchecked matches uri like Match, without checking constraints.
+func (t *Template) matchUnchecked(uri string) (map[string]string, bool) {
+	if values, ok := t.match(uri); ok {
+		return values, true
+	}
+	for _, convert := range []func(string) (string, error){HostToUnicode, HostToASCII} {
+		if converted := mapHost(uri, convert); converted != uri {
+			if values, ok := t.match(converted); ok {
+				return values, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (t *Template) match(uri string) (map[string]string, bool) {
+	values := make(map[string]string)
+	pos := 0
+	for i := 0; i < len(t.parts); i++ {
+		p := t.parts[i]
+		if p.terms == nil {
+			if !strings.HasPrefix(uri[pos:], p.raw) {
+				return nil, false
+			}
+			pos += len(p.raw)
+			continue
+		}
+		if p.op == '?' || p.op == '&' {
+			// Query expressions directly followed by "&" expressions share
+			// one query string, in which parameters may appear in any order.
+			terms := p.terms
+			for j := i + 1; j < len(t.parts); j++ {
+				if t.parts[j].op == '&' {
+					terms = append(terms[:len(terms):len(terms)], t.parts[j].terms...)
+					i = j
+				} else if t.parts[j].terms != nil || len(t.parts[j].raw) > 0 {
+					break
+				}
+			}
+			// When the "?" expression expands to nothing, the query
+			// string begins with the "&" of the expressions that follow.
+			if len(terms) > len(p.terms) && p.first == "?" && strings.HasPrefix(uri[pos:], "&") {
+				p.first = "&"
+			}
+			p.terms = terms
+		}
+		end := pos + p.matchLen(uri[pos:], t.nextLiteral(i))
+		if !p.match(uri[pos:end], values) {
+			return nil, false
+		}
+		pos = end
+	}
+	if pos != len(uri) {
+		return nil, false
+	}
+	return values, true
+}
+
+// nextLiteral returns the literal text following part i, if any.
+func (t *Template) nextLiteral(i int) string {
+	for _, p := range t.parts[i+1:] {
+		if p.terms == nil && len(p.raw) > 0 {
+			return p.raw
+		}
+		if p.terms != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// matchLen returns the length of the prefix of s that an expansion of the
+// expression may have produced.
+func (p *templatePart) matchLen(s string, next string) int {
+	if len(p.first) > 0 && !strings.HasPrefix(s, p.first) {
+		return 0
+	}
+	n := len(s)
+	if i := strings.IndexAny(s[len(p.first):], p.stops()); i >= 0 {
+		n = len(p.first) + i
+	}
+	if len(next) > 0 {
+		if i := strings.Index(s[len(p.first):], next); i >= 0 && len(p.first)+i < n {
+			n = len(p.first) + i
+		}
+	}
+	return n
+}
+
+// stops returns the characters that cannot occur in an expansion of the
+// expression after its prefix.
+func (p *templatePart) stops() string {
+	switch p.op {
+	case '?', '&':
+		return "#"
+	case ';':
+		return "/?#"
+	case '/', '+':
+		return "?#"
+	case '#':
+		return ""
+	}
+	return "/?#&;="
+}
+
+func (p *templatePart) match(s string, values map[string]string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	s = s[len(p.first):]
+	if p.named {
+		return p.matchNamed(s, values)
+	}
+	last := p.terms[len(p.terms)-1]
+	var pieces []string
+	if last.explode {
+		pieces = strings.Split(s, p.sep)
+	} else {
+		pieces = strings.SplitN(s, p.sep, len(p.terms))
+	}
+	for i, term := range p.terms {
+		if i >= len(pieces) {
+			break
+		}
+		piece := pieces[i]
+		if term.explode {
+			piece = strings.Join(pieces[i:], ",")
+		}
+		value, err := url.PathUnescape(piece)
+		if err != nil {
+			return false
+		}
+		values[term.name] = value
+	}
+	return true
+}
+
+func (p *templatePart) matchNamed(s string, values map[string]string) bool {
+	names := make(map[string]bool)
+	explode := false
+	for _, term := range p.terms {
+		names[term.name] = true
+		explode = explode || term.explode
+	}
+	for _, param := range strings.Split(s, p.sep) {
+		name, value := splitPair(param)
+		name, err := url.PathUnescape(name)
+		if err != nil {
+			return false
+		}
+		if !names[name] && !explode {
+			continue
+		}
+		value, err = url.PathUnescape(value)
+		if err != nil {
+			return false
+		}
+		if previous, exists := values[name]; exists {
+			value = previous + "," + value
+		}
+		values[name] = value
+	}
+	return true
+}
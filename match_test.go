@@ -0,0 +1,105 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		raw string
+		uri string
+		out map[string]string
+		ok  bool
+	}{
+		{"http://localhost:8080/{id}", "http://localhost:8080/foo", map[string]string{"id": "foo"}, true},
+		{"http://localhost:8080/{id}", "http://localhost:8080/a%20b", map[string]string{"id": "a b"}, true},
+		{"http://localhost:8080/{id}", "http://localhost:8080/foo/bar", nil, false},
+		{"http://localhost:8080/{id}", "http://example.com/foo", nil, false},
+		{"/users/{id}/posts/{post}", "/users/1/posts/2", map[string]string{"id": "1", "post": "2"}, true},
+		{"/items{?a,b}", "/items?a=1&b=2", map[string]string{"a": "1", "b": "2"}, true},
+		{"/items{?a,b}", "/items?b=2&a=1", map[string]string{"a": "1", "b": "2"}, true},
+		{"/items{?a,b}", "/items?b=x%20y", map[string]string{"b": "x y"}, true},
+		{"/items{?a,b}", "/items?c=3&b=2", map[string]string{"b": "2"}, true},
+		{"/items{?a,b}", "/items", map[string]string{}, true},
+		{"/items{?a}{&b}", "/items?a=1&b=2", map[string]string{"a": "1", "b": "2"}, true},
+		{"/items{?a}{&b}", "/items&b=2", map[string]string{"b": "2"}, true},
+		{"/items{?a}", "/items&a=1", nil, false},
+		{"/items?fixed=1{&a,b}", "/items?fixed=1&b=2&a=1", map[string]string{"a": "1", "b": "2"}, true},
+		{"/items{/a,b}{?q}", "/items/x/y?q=z", map[string]string{"a": "x", "b": "y", "q": "z"}, true},
+		{"/items{/a}/edit", "/items/x/edit", map[string]string{"a": "x"}, true},
+		{"/file{.ext}", "/file.json", map[string]string{"ext": "json"}, true},
+		{"/map{;x,y}", "/map;y=2;x=1", map[string]string{"x": "1", "y": "2"}, true},
+		{"/items/{a,b}", "/items/1,2", map[string]string{"a": "1", "b": "2"}, true},
+		{"/items/{id}", "/items/%zz", nil, false},
+		{"{+base}/items", "http://example.com/api/items", map[string]string{"base": "http://example.com/api"}, true},
+		{"{+base}{?q}", "http://example.com/a,b?q=1", map[string]string{"base": "http://example.com/a,b", "q": "1"}, true},
+		{"/page{#section}", "/page#a/b?c", map[string]string{"section": "a/b?c"}, true},
+		{"/page{#section}", "/page", map[string]string{}, true},
+		{"/page{?q}{#section}", "/page?q=1#top", map[string]string{"q": "1", "section": "top"}, true},
+		{"/files{/path*}", "/files/a/b/c", map[string]string{"path": "a,b,c"}, true},
+		{"/files{/path*}{?q}", "/files/a%2Fb/c?q=x", map[string]string{"path": "a/b,c", "q": "x"}, true},
+		{"/files{/dir,path*}", "/files/d/a/b", map[string]string{"dir": "d", "path": "a,b"}, true},
+		{"/files/{path}", "/files/a/b", nil, false},
+		{"/items{?list*}", "/items?list=a&list=b", map[string]string{"list": "a,b"}, true},
+		{"/items{?params*}", "/items?x=1&y=2", map[string]string{"x": "1", "y": "2"}, true},
+		{"/items{?a}", "/items?a=", map[string]string{"a": ""}, true},
+		{"/items{?a}", "/items?a=1%2B1+2", map[string]string{"a": "1+1+2"}, true},
+		{"/items{?a}", "/items?a=%", nil, false},
+		{"/items{&a}", "/items&a=1", map[string]string{"a": "1"}, true},
+		{"/map{;x,y}", "/map;x;y=2", map[string]string{"x": "", "y": "2"}, true},
+		{"/file{.ext*}", "/file.tar.gz", map[string]string{"ext": "tar,gz"}, true},
+		{"/file{.a,b}", "/file.x.y", map[string]string{"a": "x", "b": "y"}, true},
+		{"/x/{a}", "/x/", map[string]string{}, true},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, ok := template.Match(test.uri)
+			if ok != test.ok {
+				t.Fatalf("want ok %t, got %t", test.ok, ok)
+			}
+			if !reflect.DeepEqual(test.out, out) {
+				t.Errorf("want %v, got %v", test.out, out)
+			}
+		})
+	}
+}
+
+func TestMatchExpand(t *testing.T) {
+	tests := []struct {
+		raw    string
+		values map[string]interface{}
+	}{
+		{"/p{?a}{&b}", map[string]interface{}{"b": "2"}},
+		{"/p{?a}{&b}", map[string]interface{}{"a": "1"}},
+		{"/p{?a}{&b}", map[string]interface{}{"a": "1", "b": "2"}},
+		{"/p{?a}{&b}", map[string]interface{}{}},
+		{"/p{?a}{&b,c}", map[string]interface{}{"c": "3"}},
+	}
+	for _, test := range tests {
+		template := MustParse(test.raw)
+		uri, err := template.Expand(test.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := template.Match(uri)
+		if !ok {
+			t.Errorf("%s: %s does not match", test.raw, uri)
+			continue
+		}
+		for name, value := range test.values {
+			if out[name] != value {
+				t.Errorf("%s: %s: want %s=%v, got %v", test.raw, uri, name, value, out)
+			}
+		}
+		if len(out) != len(test.values) {
+			t.Errorf("%s: %s: want %v, got %v", test.raw, uri, test.values, out)
+		}
+	}
+}
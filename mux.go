@@ -0,0 +1,119 @@
+package uri
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Mux is an HTTP request multiplexer that routes requests by URI template.
+// Each request is dispatched to the handler of the first registered template
+// that matches its URL, as by Template.Match, and the matched variables are
+// available to the handler through RequestVars.
+//
+// A template matches the path of the request, or the path and the query if
+// the template has a query expression ("?" or "&").
+type Mux struct {
+	// NotFound handles requests that match no template. It defaults to
+	// http.NotFoundHandler.
+	NotFound http.Handler
+
+	mu     sync.RWMutex
+	routes []route
+}
+
+type route struct {
+	method   string
+	template *Template
+	query    bool
+	handler  http.Handler
+}
+
+type varsKey struct{}
+
+// NewMux returns a new, empty Mux.
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Handle registers the handler for the given pattern, which is a URI
+// template optionally preceded by an HTTP method and a space, such as
+// "GET /users/{id}". A pattern without a method matches all methods. Handle
+// panics if the template cannot be parsed.
+func (m *Mux) Handle(pattern string, handler http.Handler) {
+	method := ""
+	if i := strings.IndexByte(pattern, ' '); i >= 0 && !strings.ContainsAny(pattern[:i], "/{") {
+		method, pattern = pattern[:i], strings.TrimLeft(pattern[i+1:], " ")
+	}
+	m.HandleTemplate(method, MustParse(pattern), handler)
+}
+
+// HandleTemplate registers the handler for a parsed template, such as one
+// with constraints, and for requests with the given method, or for all
+// methods if method is empty.
+func (m *Mux) HandleTemplate(method string, template *Template, handler http.Handler) {
+	query := false
+	for _, p := range template.parts {
+		if p.op == '?' || p.op == '&' {
+			query = true
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, route{method, template, query, handler})
+}
+
+// HandleFunc registers the handler function for the given pattern, as
+// described for Handle.
+func (m *Mux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// ServeHTTP dispatches the request to the handler of the first template
+// that matches its URL. If templates match only for other methods, it
+// replies with 405 Method Not Allowed and an Allow header listing them.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	routes := m.routes
+	m.mu.RUnlock()
+	var allowed []string
+	for _, route := range routes {
+		target := r.URL.EscapedPath()
+		if route.query && r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		vars, ok := route.template.Match(target)
+		if !ok {
+			continue
+		}
+		if route.method != "" && route.method != r.Method {
+			if !contains(allowed, route.method) {
+				allowed = append(allowed, route.method)
+			}
+			continue
+		}
+		ctx := context.WithValue(r.Context(), varsKey{}, vars)
+		route.handler.ServeHTTP(w, r.WithContext(ctx))
+		return
+	}
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	notFound := m.NotFound
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	notFound.ServeHTTP(w, r)
+}
+
+// RequestVars returns the variables matched by the template that routed r
+// through a Mux, or nil if r was not routed by a Mux.
+func RequestVars(r *http.Request) map[string]string {
+	vars, _ := r.Context().Value(varsKey{}).(map[string]string)
+	return vars
+}
@@ -0,0 +1,69 @@
+package uri
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			vars := RequestVars(r)
+			fmt.Fprintf(w, "%s id=%s q=%s page=%s path=%s", name, vars["id"], vars["q"], vars["page"], vars["path"])
+		}
+	}
+	mux.Handle("GET /users/{id}", handler("get-user"))
+	mux.Handle("DELETE /users/{id}", handler("delete-user"))
+	mux.HandleFunc("/search{?q,page}", handler("search"))
+	mux.Handle("/files{/path*}", handler("files"))
+	tests := []struct {
+		method string
+		target string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/users/42", http.StatusOK, "get-user id=42 q= page= path=", ""},
+		{http.MethodGet, "/users/a%20b", http.StatusOK, "get-user id=a b q= page= path=", ""},
+		{http.MethodGet, "/users/42?x=1", http.StatusOK, "get-user id=42 q= page= path=", ""},
+		{http.MethodDelete, "/users/42", http.StatusOK, "delete-user id=42 q= page= path=", ""},
+		{http.MethodPut, "/users/42", http.StatusMethodNotAllowed, "", "DELETE, GET"},
+		{http.MethodPost, "/search?page=2&q=go", http.StatusOK, "search id= q=go page=2 path=", ""},
+		{http.MethodGet, "/search", http.StatusOK, "search id= q= page= path=", ""},
+		{http.MethodGet, "/files/a/b", http.StatusOK, "files id= q= page= path=a,b", ""},
+		{http.MethodGet, "/nothing", http.StatusNotFound, "", ""},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(test.method, test.target, nil))
+			if w.Code != test.code {
+				t.Fatalf("want status %d, got %d", test.code, w.Code)
+			}
+			if test.code == http.StatusOK && w.Body.String() != test.body {
+				t.Errorf("want %q, got %q", test.body, w.Body.String())
+			}
+			if allow := w.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("want Allow %q, got %q", test.allow, allow)
+			}
+		})
+	}
+}
+
+func TestMuxNotFound(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("want status %d, got %d", http.StatusTeapot, w.Code)
+	}
+	if vars := RequestVars(httptest.NewRequest(http.MethodGet, "/", nil)); vars != nil {
+		t.Errorf("want nil vars, got %v", vars)
+	}
+}
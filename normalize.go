@@ -0,0 +1,140 @@
+package uri
+
+import (
+	"strings"
+)
+
+// A Normalizer rewrites URIs into an equivalent normal form as described by
+// RFC 3986 section 6.2, so that equivalent URIs compare equal. Each field
+// enables one normalization.
+type Normalizer struct {
+	// Case lowercases the scheme and host and uppercases the hexadecimal
+	// digits of percent-encodings (section 6.2.2.1).
+	Case bool
+	// Percent decodes percent-encoded unreserved characters (section
+	// 6.2.2.2).
+	Percent bool
+	// DotSegments removes "." and ".." path segments (section 6.2.2.3).
+	DotSegments bool
+	// DefaultPort removes the port if it is the default of the scheme, for
+	// http, https, ws, wss and ftp (section 6.2.3).
+	DefaultPort bool
+	// EmptyPath replaces an empty path with "/" for URIs that have an
+	// authority (section 6.2.3).
+	EmptyPath bool
+}
+
+// DefaultNormalizer is the Normalizer used by Normalize. It applies all of
+// the syntax-based normalizations of RFC 3986 section 6.2.2.
+var DefaultNormalizer = Normalizer{Case: true, Percent: true, DotSegments: true}
+
+// Normalize normalizes uri with DefaultNormalizer.
+func Normalize(uri string) (string, error) {
+	return DefaultNormalizer.Normalize(uri)
+}
+
+var defaultPorts = map[string]string{
+	"ftp":   "21",
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Normalize parses uri with ParseURI and returns its normal form.
+func (n Normalizer) Normalize(uri string) (string, error) {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return "", err
+	}
+	n.normalize(u)
+	return u.String(), nil
+}
+
+func (n Normalizer) normalize(u *URI) {
+	if n.Case {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+	}
+	if n.Case || n.Percent {
+		for _, component := range []*string{&u.UserInfo, &u.Host, &u.Path, &u.Query, &u.Fragment} {
+			*component = normalizeTriplets(*component, n.Percent)
+		}
+	}
+	if n.DotSegments && (u.Scheme != "" || u.HasAuthority || strings.HasPrefix(u.Path, "/")) {
+		u.Path = removeDotSegments(u.Path)
+	}
+	if n.DefaultPort && u.HasPort && (u.Port == "" || u.Port == defaultPorts[strings.ToLower(u.Scheme)]) {
+		u.Port, u.HasPort = "", false
+	}
+	if n.EmptyPath && u.HasAuthority && u.Path == "" {
+		u.Path = "/"
+	}
+}
+
+// normalizeTriplets uppercases the hexadecimal digits of the percent-encoded
+// triplets in s and, if decode is set, decodes those of unreserved
+// characters.
+func normalizeTriplets(s string, decode bool) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if !isTriplet(s, i) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if decode && isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(upperHex(s[i+1]))
+			b.WriteByte(upperHex(s[i+2]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments interprets and removes the "." and ".." segments of a
+// path, as described by RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	var out []string
+	in := path
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			i := strings.IndexByte(in[1:], '/')
+			if i < 0 {
+				out = append(out, in)
+				in = ""
+			} else {
+				out = append(out, in[:i+1])
+				in = in[i+1:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
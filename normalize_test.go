@@ -0,0 +1,80 @@
+package uri
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"HTTP://www.Example.COM/", "http://www.example.com/"},
+		{"http://example.com/%7euser/%2fa%2Fb", "http://example.com/~user/%2Fa%2Fb"},
+		{"http://example.com/a/./b/../c/%2E%2E/d", "http://example.com/a/d"},
+		{"http://example.com/../../a", "http://example.com/a"},
+		{"http://example.com:80", "http://example.com:80"},
+		{"http://User@Example.com/?Q=%4a#F%3a", "http://User@example.com/?Q=J#F%3A"},
+		{"mailto:Joe@Example.COM", "mailto:Joe@Example.COM"},
+		{"../a/./b", "../a/./b"},
+		{"/a/../../b/.", "/b/"},
+	}
+	for _, test := range tests {
+		out, err := Normalize(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != test.out {
+			t.Errorf("%s: want %s, got %s", test.in, test.out, out)
+		}
+	}
+	if _, err := Normalize("http://exa mple.com/"); err == nil {
+		t.Error("want error for an invalid URI")
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		n   Normalizer
+		in  string
+		out string
+	}{
+		{Normalizer{}, "HTTP://Example.com:80/./a/%7e", "HTTP://Example.com:80/./a/%7e"},
+		{Normalizer{Case: true}, "HTTP://Example.com/%7e", "http://example.com/%7E"},
+		{Normalizer{Percent: true}, "HTTP://Example.com/%7e%2f", "HTTP://Example.com/~%2F"},
+		{Normalizer{DotSegments: true}, "http://example.com/a/../b", "http://example.com/b"},
+		{Normalizer{DefaultPort: true}, "https://example.com:443/", "https://example.com/"},
+		{Normalizer{DefaultPort: true}, "https://example.com:/", "https://example.com/"},
+		{Normalizer{DefaultPort: true}, "https://example.com:80/", "https://example.com:80/"},
+		{Normalizer{EmptyPath: true}, "http://example.com?q", "http://example.com/?q"},
+	}
+	for _, test := range tests {
+		out, err := test.n.Normalize(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != test.out {
+			t.Errorf("%+v %s: want %s, got %s", test.n, test.in, test.out, out)
+		}
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	// RFC 3986 section 5.2.4
+	tests := []struct {
+		in, out string
+	}{
+		{"/a/b/c/./../../g", "/a/g"},
+		{"mid/content=5/../6", "mid/6"},
+		{"/./", "/"},
+		{"/..", "/"},
+		{"/a/b/..", "/a/"},
+		{".", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if out := removeDotSegments(test.in); out != test.out {
+			t.Errorf("%s: want %s, got %s", test.in, test.out, out)
+		}
+	}
+}
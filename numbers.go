@@ -0,0 +1,102 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A NumberFormat controls how integers and floating-point numbers are
+// formatted when they are expanded. The zero value formats them as fmt's %v
+// verb does, which writes large floats in scientific notation ("1e+06").
+type NumberFormat struct {
+	// Float is a format of strconv.FormatFloat, one of 'b', 'e', 'E', 'f',
+	// 'g', 'G', 'x' and 'X', optionally followed by the precision: "f"
+	// formats 1e6 as "1000000" and 2.5 as "2.5", "f2" formats 2.5 as "2.50"
+	// and "e3" formats 1e6 as "1.000e+06". Without a precision, the fewest
+	// digits that represent the value exactly are used.
+	Float string
+
+	// Base is the base of integers, from 2 to 36, with digits beyond 9
+	// written as lowercase letters. It defaults to 10.
+	Base int
+}
+
+// override returns f with the non-zero settings of other replacing its own.
+func (f NumberFormat) override(other NumberFormat) NumberFormat {
+	if other.Float != "" {
+		f.Float = other.Float
+	}
+	if other.Base != 0 {
+		f.Base = other.Base
+	}
+	return f
+}
+
+// format formats value if it is a number whose formatting f changes, and
+// reports whether it did. Numbers that format themselves, such as
+// time.Duration, are left alone.
+func (f NumberFormat) format(value interface{}) (string, bool, error) {
+	if isScalar(value) {
+		return "", false, nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.Base == 0 || f.Base == 10 {
+			return "", false, nil
+		}
+		if f.Base < 2 || f.Base > 36 {
+			return "", false, fmt.Errorf("invalid integer base %d", f.Base)
+		}
+		return strconv.FormatInt(v.Int(), f.Base), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f.Base == 0 || f.Base == 10 {
+			return "", false, nil
+		}
+		if f.Base < 2 || f.Base > 36 {
+			return "", false, fmt.Errorf("invalid integer base %d", f.Base)
+		}
+		return strconv.FormatUint(v.Uint(), f.Base), true, nil
+	case reflect.Float32, reflect.Float64:
+		if f.Float == "" {
+			return "", false, nil
+		}
+		verb, prec, err := parseFloatFormat(f.Float)
+		if err != nil {
+			return "", false, err
+		}
+		return strconv.FormatFloat(v.Float(), verb, prec, v.Type().Bits()), true, nil
+	}
+	return "", false, nil
+}
+
+func parseFloatFormat(s string) (verb byte, prec int, err error) {
+	if s == "" || !strings.ContainsRune("beEfgGxX", rune(s[0])) {
+		return 0, 0, fmt.Errorf("invalid float format %q", s)
+	}
+	if len(s) == 1 {
+		return s[0], -1, nil
+	}
+	prec, err = strconv.Atoi(s[1:])
+	if err != nil || prec < 0 {
+		return 0, 0, fmt.Errorf("invalid float format %q", s)
+	}
+	return s[0], prec, nil
+}
+
+// parseNumberOption sets the number format of f from a float= or base=
+// option of a uri tag. Invalid settings are ignored, like unknown options.
+func parseNumberOption(f *NumberFormat, option string) {
+	switch {
+	case strings.HasPrefix(option, "float="):
+		if _, _, err := parseFloatFormat(option[len("float="):]); err == nil {
+			f.Float = option[len("float="):]
+		}
+	case strings.HasPrefix(option, "base="):
+		if base, err := strconv.Atoi(option[len("base="):]); err == nil && base >= 2 && base <= 36 {
+			f.Base = base
+		}
+	}
+}
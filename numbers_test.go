@@ -0,0 +1,69 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpandNumbers(t *testing.T) {
+	values := map[string]interface{}{
+		"big":     1e6,
+		"price":   2.5,
+		"small":   float32(0.1),
+		"id":      255,
+		"flags":   uint8(5),
+		"list":    []float64{1e7, 0.25},
+		"timeout": time.Second,
+		"ids":     []time.Duration{2 * time.Second, 3 * time.Second},
+		"code":    statusCode(200),
+	}
+	tests := []struct {
+		raw  string
+		opts ExpandOpts
+		out  string
+	}{
+		{"{big,price,small,id}", ExpandOpts{}, "1e%2B06,2.5,0.1,255"},
+		{"{big,price,small,id}", ExpandOpts{Numbers: NumberFormat{Float: "f"}}, "1000000,2.5,0.1,255"},
+		{"{big,price}", ExpandOpts{Numbers: NumberFormat{Float: "f2"}}, "1000000.00,2.50"},
+		{"{big}", ExpandOpts{Numbers: NumberFormat{Float: "e3"}}, "1.000e%2B06"},
+		{"{?list*}", ExpandOpts{Numbers: NumberFormat{Float: "f"}}, "?list=10000000&list=0.25"},
+		{"{id,flags}", ExpandOpts{Numbers: NumberFormat{Base: 16}}, "ff,5"},
+		{"{id,flags}", ExpandOpts{Numbers: NumberFormat{Base: 2}}, "11111111,101"},
+		{"{id,price}", ExpandOpts{Numbers: NumberFormat{Float: "f1"}, NumberFormats: map[string]NumberFormat{"id": {Base: 16}, "price": {Float: "f"}}}, "ff,2.5"},
+		{"{big}", ExpandOpts{Numbers: NumberFormat{Float: "f"}, NumberFormats: map[string]NumberFormat{"big": {Base: 16}}}, "1000000"},
+		{"{timeout,ids}", ExpandOpts{Numbers: NumberFormat{Base: 16}}, "1s,2s,3s"},
+		{"{code}", ExpandOpts{Numbers: NumberFormat{Base: 16}}, "200%20OK"},
+	}
+	for i, test := range tests {
+		out, err := MustParse(test.raw).ExpandWithOpts(values, test.opts)
+		if err != nil || out != test.out {
+			t.Errorf("%d: %s: want %s, got %s, %v", i, test.raw, test.out, out, err)
+		}
+	}
+	for _, opts := range []ExpandOpts{{Numbers: NumberFormat{Float: "q"}}, {Numbers: NumberFormat{Float: "f-1"}}, {Numbers: NumberFormat{Base: 37}}} {
+		if out, err := MustParse("{big,id}").ExpandWithOpts(values, opts); err == nil {
+			t.Errorf("%+v: want error, got %s", opts.Numbers, out)
+		}
+	}
+}
+
+type statusCode int
+
+func (c statusCode) String() string {
+	return fmt.Sprintf("%d OK", int(c))
+}
+
+func TestExpandNumberTags(t *testing.T) {
+	type item struct {
+		Price   float64       `uri:"price,float=f2"`
+		ID      int           `uri:"id,base=16"`
+		Timeout time.Duration `uri:"timeout,base=16"`
+		Weight  float64       `uri:"weight,omitempty,float=f"`
+		Bad     float64       `uri:"bad,float=z"`
+	}
+	out, err := MustParse("/items/{id}{?price,timeout,weight,bad}").ExpandWithOpts(item{Price: 3, ID: 4095, Timeout: time.Minute, Weight: 2e6, Bad: 1e21}, ExpandOpts{Numbers: NumberFormat{Float: "e"}})
+	if expected := "/items/fff?price=3.00&timeout=1m0s&weight=2000000&bad=1e%2B21"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+}
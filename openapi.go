@@ -0,0 +1,212 @@
+package uri
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// An OpenAPIParam describes a path or query parameter of an OpenAPI
+// operation. Its fields and JSON names are those of the OpenAPI Parameter
+// Object that concern the serialization of the parameter.
+type OpenAPIParam struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	// Style is "simple", "label" or "matrix" for path parameters and
+	// "form" for query parameters. Empty means "simple" in the path and
+	// "form" in the query, as in OpenAPI.
+	Style string `json:"style,omitempty"`
+	// Explode defaults to true for the "form" style and to false for the
+	// others, as in OpenAPI.
+	Explode *bool `json:"explode,omitempty"`
+}
+
+func (p OpenAPIParam) style() string {
+	switch {
+	case p.Style != "":
+		return p.Style
+	case p.In == "query":
+		return "form"
+	}
+	return "simple"
+}
+
+func (p OpenAPIParam) explode() bool {
+	if p.Explode != nil {
+		return *p.Explode
+	}
+	return p.style() == "form"
+}
+
+// FromOpenAPI returns the template of an OpenAPI path, such as
+// "/pets/{petId}", whose parameters are described by params. Each path
+// parameter becomes an expression for its style: "{petId}" for "simple",
+// "{.petId}" for "label" and "{;petId}" for "matrix", exploded if Explode
+// is set. The query parameters are appended in order as a single form-style
+// query expression, such as "{?limit,tags*}". Parameters of other locations
+// are ignored, and path parameters that params does not describe use the
+// "simple" style. Characters that RFC 6570 does not allow in variable names,
+// such as "-", are percent-encoded in the names of the template.
+//
+// The styles spaceDelimited, pipeDelimited and deepObject have no RFC 6570
+// equivalent and are rejected with an error.
+func FromOpenAPI(path string, params []OpenAPIParam) (*Template, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(path, '{')
+		if i < 0 {
+			break
+		}
+		end := strings.IndexByte(path[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed parameter in %q", path)
+		}
+		name := path[i+1 : i+end]
+		p := OpenAPIParam{Name: name, In: "path"}
+		for _, param := range params {
+			if param.Name == name && param.In == "path" {
+				p = param
+			}
+		}
+		b.WriteString(path[:i])
+		b.WriteByte('{')
+		switch p.style() {
+		case "simple":
+		case "label":
+			b.WriteByte('.')
+		case "matrix":
+			b.WriteByte(';')
+		default:
+			return nil, fmt.Errorf("parameter %s: unsupported path style %s", name, p.style())
+		}
+		b.WriteString(openAPIName(name, p.explode()) + "}")
+		path = path[i+end+1:]
+	}
+	b.WriteString(path)
+	var query []string
+	for _, p := range params {
+		if p.In != "query" {
+			continue
+		}
+		if p.style() != "form" {
+			return nil, fmt.Errorf("parameter %s: unsupported query style %s", p.Name, p.style())
+		}
+		query = append(query, openAPIName(p.Name, p.explode()))
+	}
+	if len(query) > 0 {
+		b.WriteString("{?" + strings.Join(query, ",") + "}")
+	}
+	return Parse(b.String())
+}
+
+// openAPIName returns the variable name of an OpenAPI parameter, with the
+// explode modifier if explode is set.
+func openAPIName(name string, explode bool) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; isAlnum(c) || c == '_' || c == '.' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	if explode {
+		b.WriteByte('*')
+	}
+	return b.String()
+}
+
+func isAlnum(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
+
+// ToOpenAPI returns the OpenAPI path of the template and the parameters it
+// references, in order of first appearance. It is the inverse of
+// FromOpenAPI: "{id}", "{.id}" and "{;id}" become the path parameter
+// "{id}" with the style "simple", "label" or "matrix", "{/id}" becomes
+// "/{id}", and query expressions, which must follow the path, become
+// form-style query parameters. Path parameters are required. Variable names
+// are percent-decoded.
+//
+// An error is returned for templates that OpenAPI cannot describe, such as
+// those with a fragment, with reserved expansion ("{+path}"), with prefix
+// modifiers, with an exploded "{/path*}" expression, or with a variable
+// used both in the path and in the query.
+func (t *Template) ToOpenAPI() (path string, params []OpenAPIParam, err error) {
+	var b strings.Builder
+	index := make(map[string]int)
+	add := func(term templateTerm, in, style string) (string, error) {
+		name, err := url.PathUnescape(term.name)
+		if err != nil {
+			return "", fmt.Errorf("variable %s: %v", term.name, err)
+		}
+		p := OpenAPIParam{Name: name, In: in, Required: in == "path", Style: style}
+		if term.explode != p.explode() {
+			explode := term.explode
+			p.Explode = &explode
+		}
+		if i, exists := index[name]; exists {
+			if params[i].In != p.In || params[i].style() != p.style() || params[i].explode() != p.explode() {
+				return "", fmt.Errorf("variable %s is used in incompatible ways", name)
+			}
+			return name, nil
+		}
+		index[name] = len(params)
+		params = append(params, p)
+		return name, nil
+	}
+	query := false
+	for _, p := range t.parts {
+		if p.terms == nil {
+			if query && p.raw != "" || strings.ContainsAny(p.raw, "?#") {
+				return "", nil, fmt.Errorf("literal %q cannot be part of an OpenAPI path", p.raw)
+			}
+			b.WriteString(p.raw)
+			continue
+		}
+		for _, term := range p.terms {
+			if term.truncate > 0 {
+				return "", nil, fmt.Errorf("variable %s: prefix modifiers are not supported", term.name)
+			}
+		}
+		if p.op == '?' || p.op == '&' {
+			query = true
+			for _, term := range p.terms {
+				if _, err := add(term, "query", ""); err != nil {
+					return "", nil, err
+				}
+			}
+			continue
+		}
+		if query {
+			return "", nil, fmt.Errorf("expression {%s} cannot follow the query", p.expr)
+		}
+		style := ""
+		switch p.op {
+		case 0:
+		case '.':
+			style = "label"
+		case ';':
+			style = "matrix"
+		case '/':
+			b.WriteByte('/')
+		default:
+			return "", nil, fmt.Errorf("expression {%s}: operator %c is not supported", p.expr, p.op)
+		}
+		for i, term := range p.terms {
+			if p.op == '/' && term.explode {
+				return "", nil, fmt.Errorf("expression {%s}: exploded path segments are not supported", p.expr)
+			}
+			if i > 0 && (p.op == 0 || p.op == '/') {
+				b.WriteString(p.sep)
+			}
+			name, err := add(term, "path", style)
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString("{" + name + "}")
+		}
+	}
+	return b.String(), params, nil
+}
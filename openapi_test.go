@@ -0,0 +1,116 @@
+package uri
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFromOpenAPI(t *testing.T) {
+	tests := []struct {
+		path     string
+		params   string
+		expected string
+	}{
+		{"/pets/{petId}", `[{"name":"petId","in":"path","required":true}]`, "/pets/{petId}"},
+		{"/pets/{petId}", `[]`, "/pets/{petId}"},
+		{"/pets/{id}", `[{"name":"id","in":"path","style":"simple","explode":true}]`, "/pets/{id*}"},
+		{"/pets/{id}", `[{"name":"id","in":"path","style":"label"}]`, "/pets/{.id}"},
+		{"/pets/{id}", `[{"name":"id","in":"path","style":"matrix","explode":true}]`, "/pets/{;id*}"},
+		{"/pets", `[{"name":"limit","in":"query"},{"name":"tags","in":"query","explode":false},{"name":"X-Key","in":"header"}]`, "/pets{?limit*,tags}"},
+		{"/users/{user-id}", `[{"name":"page","in":"query","style":"form","explode":false}]`, "/users/{user%2Did}{?page}"},
+	}
+	for _, test := range tests {
+		var params []OpenAPIParam
+		if err := json.Unmarshal([]byte(test.params), &params); err != nil {
+			t.Fatal(err)
+		}
+		template, err := FromOpenAPI(test.path, params)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+			continue
+		}
+		if template.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.path, test.expected, template.String())
+		}
+	}
+}
+
+func TestFromOpenAPIError(t *testing.T) {
+	tests := []struct {
+		path   string
+		params []OpenAPIParam
+	}{
+		{"/pets/{petId", nil},
+		{"/pets/{}", nil},
+		{"/pets/{id}", []OpenAPIParam{{Name: "id", In: "path", Style: "form"}}},
+		{"/pets", []OpenAPIParam{{Name: "ids", In: "query", Style: "pipeDelimited"}}},
+		{"/pets", []OpenAPIParam{{Name: "filter", In: "query", Style: "deepObject"}}},
+	}
+	for _, test := range tests {
+		if template, err := FromOpenAPI(test.path, test.params); err == nil {
+			t.Errorf("%s: expected error, got %q", test.path, template)
+		}
+	}
+}
+
+func TestToOpenAPI(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		template string
+		path     string
+		params   []OpenAPIParam
+	}{
+		{"/pets/{petId}", "/pets/{petId}", []OpenAPIParam{{Name: "petId", In: "path", Required: true}}},
+		{"/pets{/id}", "/pets/{id}", []OpenAPIParam{{Name: "id", In: "path", Required: true}}},
+		{"/a{/x,y}", "/a/{x}/{y}", []OpenAPIParam{{Name: "x", In: "path", Required: true}, {Name: "y", In: "path", Required: true}}},
+		{"/a/{x,y}", "/a/{x},{y}", []OpenAPIParam{{Name: "x", In: "path", Required: true}, {Name: "y", In: "path", Required: true}}},
+		{"/a/{id*}{.fmt}{;v}", "/a/{id}{fmt}{v}", []OpenAPIParam{
+			{Name: "id", In: "path", Required: true, Explode: &yes},
+			{Name: "fmt", In: "path", Required: true, Style: "label"},
+			{Name: "v", In: "path", Required: true, Style: "matrix"},
+		}},
+		{"/users/{user%2Did}/{user%2Did}{?q,tags*}{&page}", "/users/{user-id}/{user-id}", []OpenAPIParam{
+			{Name: "user-id", In: "path", Required: true},
+			{Name: "q", In: "query", Explode: &no},
+			{Name: "tags", In: "query"},
+			{Name: "page", In: "query", Explode: &no},
+		}},
+	}
+	for _, test := range tests {
+		path, params, err := MustParse(test.template).ToOpenAPI()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.template, err)
+			continue
+		}
+		if path != test.path || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: expected %q, %+v, got %q, %+v", test.template, test.path, test.params, path, params)
+		}
+		template, err := FromOpenAPI(path, params)
+		if err != nil {
+			t.Errorf("%s: FromOpenAPI: %v", test.template, err)
+			continue
+		}
+		if again, againParams, _ := template.ToOpenAPI(); again != path || !reflect.DeepEqual(againParams, params) {
+			t.Errorf("%s: round trip through %q gave %q, %+v", test.template, template, again, againParams)
+		}
+	}
+}
+
+func TestToOpenAPIError(t *testing.T) {
+	tests := []string{
+		"/files{+path}",
+		"/doc{#section}",
+		"/a/{name:3}",
+		"/a{/path*}",
+		"/a{?q}/b",
+		"/a{?q}{/b}",
+		"/a?q={q}",
+		"/a/{id}{?id}",
+	}
+	for _, test := range tests {
+		if path, _, err := MustParse(test).ToOpenAPI(); err == nil {
+			t.Errorf("%s: expected error, got %q", test, path)
+		}
+	}
+}
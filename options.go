@@ -0,0 +1,726 @@
+package uri
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Escaping selects which characters are left unencoded when values are
+// expanded.
+type Escaping int
+
+const (
+	// DefaultEscaping escapes values as described by RFC 6570.
+	DefaultEscaping Escaping = iota
+	// PathSafe additionally leaves "/" unencoded in expressions that do not
+	// allow reserved characters, so that a single value can span several
+	// path segments, even with the simple operator.
+	PathSafe
+	// OAuth1 applies the rules for OAuth 1.0 signature base strings (RFC
+	// 5849 section 3.4.1.3.2): only unreserved characters are left
+	// unencoded, even in expressions that allow reserved characters,
+	// percent-encodings use uppercase hexadecimal digits, and the parameters
+	// of each query expression are sorted by name, then by value.
+	OAuth1
+	// FragmentSafe escapes fragment ("#") expressions according to the
+	// fragment production of RFC 3986, which unlike the reserved set of RFC
+	// 6570 does not allow "#", "[" and "]".
+	FragmentSafe
+	// StrictReserved leaves only unreserved characters and the generic
+	// delimiters ":/?#[]@" unencoded in expressions that allow reserved
+	// characters, encoding the sub-delimiters "!$&'()*+,;=" for stricter
+	// backends.
+	StrictReserved
+)
+
+// ExpandOpts holds optional settings for ExpandWithOpts and ExpandWith. The
+// zero value expands according to RFC 6570.
+type ExpandOpts struct {
+	Escaping Escaping
+
+	// Strict returns an error naming every variable of the template that
+	// the expanded value does not define and that has no default.
+	Strict bool
+
+	// Separator, if not empty, replaces the separator an operator places
+	// between variables and between exploded elements. It is written as is,
+	// without percent-encoding, so it may contain control characters such as
+	// "\n".
+	Separator string
+
+	// IRI keeps the non-ASCII characters of values that RFC 3987 allows in
+	// IRIs, rather than percent-encoding their UTF-8 bytes, so that the
+	// expansion is an IRI fit for display. Use ToURI to convert it for the
+	// wire. IRI has no effect with OAuth1 escaping.
+	IRI bool
+
+	// IDNA converts values expanded within the host of the template, such
+	// as "{host}" in "https://{host}/", to their A-label form with
+	// HostToASCII, so that "bücher.example" expands to
+	// "xn--bcher-kva.example".
+	IDNA bool
+
+	// LowerHex emits lowercase hexadecimal digits in percent-encodings
+	// ("%2f" instead of "%2F"). RFC 3986 recommends uppercase, which is the
+	// default.
+	LowerHex bool
+
+	// SortQuery sorts the parameters produced by each query expression ("?"
+	// and "&" operators) by name, then by value. All other expressions keep
+	// their template order.
+	SortQuery bool
+
+	// JSONTags names struct fields that have no uri tag after their json
+	// tag, honoring its "-" and omitempty options.
+	JSONTags bool
+
+	// NormalizePercent treats percent-encoded triplets in values as already
+	// encoded, as described by RFC 3986 section 6.2.2.2: triplets for
+	// unreserved characters are decoded ("%41" becomes "A") and all other
+	// triplets are kept, with normalized case, rather than encoded again.
+	NormalizePercent bool
+
+	// Defaults holds values for variables that are undefined, either
+	// missing from the expanded value or set to nil, a nil pointer, an
+	// empty list or map, or a Lazy value that computes one of these, so
+	// that with the default {"page": 1} the template "/items{?page}"
+	// expands to "/items?page=1" rather than "/items". Variables set to
+	// Null are defined and keep their Null treatment.
+	Defaults map[string]interface{}
+
+	// Encoders holds functions that convert the values of specific
+	// variables to strings before they are expanded, such as base64url for
+	// a "cursor" variable or hex for binary IDs. An encoder receives the
+	// value as given, not split into list elements, and its result is
+	// percent-encoded like any other string. Variables set to Null are not
+	// passed to their encoder.
+	Encoders map[string]func(v interface{}) (string, error)
+
+	// Hooks are called in order for each defined variable of every
+	// expression before it is expanded, each with the value returned by the
+	// previous one, and before the variable's encoder.
+	Hooks []ExpandHook
+
+	// LegacyNil expands variables set to nil, or to nil pointers other than
+	// those to lists, maps and times, as "<nil>", as earlier versions did,
+	// rather than treating them as undefined.
+	LegacyNil bool
+
+	// Plus controls how "+" characters in values are expanded.
+	Plus PlusMode
+
+	// Null controls how variables set to Null are expanded.
+	Null NullMode
+
+	// NameSeparator joins the names of nested struct fields, so that the
+	// field Name of a struct in the field User is the variable "User.Name".
+	// It defaults to ".".
+	NameSeparator string
+
+	// TimeLayout is the layout used to format time.Time values, as accepted
+	// by time.Time.Format. It defaults to time.RFC3339. A layout option in a
+	// struct field's uri tag takes precedence.
+	TimeLayout string
+
+	// Numbers controls how integers and floats are formatted, and
+	// NumberFormats overrides its settings for specific variables. A float
+	// or base option in a struct field's uri tag takes precedence, as in
+	// `uri:"price,float=f2"`.
+	Numbers       NumberFormat
+	NumberFormats map[string]NumberFormat
+
+	// Bools holds the strings that booleans expand to, such as BoolOneZero
+	// or BoolYesNo. A bool option in a struct field's uri tag takes
+	// precedence, as in `uri:"active,bool=yes/no"`.
+	Bools BoolFormat
+
+	// MaxDepth limits how deeply *Template values may be nested within each
+	// other. It defaults to 10 if zero or negative.
+	MaxDepth int
+
+	// MaxReaderSize limits the number of bytes read from an io.Reader value.
+	// It defaults to 1 MiB if zero or negative.
+	MaxReaderSize int64
+}
+
+const (
+	defaultMaxDepth      = 10
+	defaultMaxReaderSize = 1 << 20
+)
+
+// Null is a value that marks a variable as explicitly null, such as a field
+// that is null in a JSON document, as opposed to one that is missing.
+var Null = null{}
+
+type null struct{}
+
+// NullMode selects how variables set to Null are expanded.
+type NullMode int
+
+const (
+	// NullSkip treats Null like an undefined variable.
+	NullSkip NullMode = iota
+	// NullEmpty expands Null like an empty string.
+	NullEmpty
+	// NullLiteral expands Null as the string "null".
+	NullLiteral
+)
+
+// PlusMode selects the treatment of "+", which RFC 3986 defines as a literal
+// sub-delimiter but form encoding interprets as a space.
+type PlusMode int
+
+const (
+	// PlusDefault encodes "+" as "%2B", except in expressions that allow
+	// reserved characters, where it is kept.
+	PlusDefault PlusMode = iota
+	// PlusPreserve keeps "+" unencoded in all expressions.
+	PlusPreserve
+	// PlusEncode encodes "+" as "%2B" in all expressions.
+	PlusEncode
+	// PlusSpace interprets "+" as a space, which is encoded as "%20".
+	PlusSpace
+)
+
+// An ExpandHook transforms or checks the values of variables as they are
+// expanded, such as to trim them, validate them or audit which values end up
+// in URIs.
+type ExpandHook interface {
+	// BeforeExpandVar returns the value to expand for the variable name in
+	// place of value. An error aborts the expansion.
+	BeforeExpandVar(name string, value interface{}) (interface{}, error)
+}
+
+// ExpandHookFunc adapts an ordinary function to an ExpandHook.
+type ExpandHookFunc func(name string, value interface{}) (interface{}, error)
+
+// BeforeExpandVar calls f(name, value).
+func (f ExpandHookFunc) BeforeExpandVar(name string, value interface{}) (interface{}, error) {
+	return f(name, value)
+}
+
+func (opts *ExpandOpts) nameSeparator() string {
+	if opts.NameSeparator == "" {
+		return "."
+	}
+	return opts.NameSeparator
+}
+
+type expander struct {
+	opts     *ExpandOpts
+	skip     map[string]bool
+	template *Template
+	chain    []string
+	trace    *[]Substitution
+	lazy     map[lazyKey]interface{}
+	// pairs holds the offsets of the pairs of a sorted query expression.
+	pairs []int
+
+	// w receives the expansion of ExpandTo, with partBuf holding the
+	// expression being expanded; flushed is set once it was partly written.
+	w       io.Writer
+	partBuf *bytes.Buffer
+	flushed bool
+}
+
+// SetTruncateHandler registers a function that is called whenever a value is
+// shortened by a prefix modifier, such as "{x:3}", during expansion.
+//
+// Deprecated: SetTruncateHandler modifies a template that may already be
+// shared between goroutines. Use WithTruncateHandler, which returns a copy.
+func (t *Template) SetTruncateHandler(handler func(name, original, truncated string)) {
+	t.truncateHandler = handler
+}
+
+// WithTruncateHandler returns a copy of t that calls handler whenever a
+// value is shortened by a prefix modifier, such as "{x:3}", during
+// expansion. The handler may be called from several goroutines at once.
+func (t *Template) WithTruncateHandler(handler func(name, original, truncated string)) *Template {
+	c := t.Clone()
+	c.truncateHandler = handler
+	return c
+}
+
+// WithPreProcess returns a copy of t that calls preProcess as described for
+// SetPreProcess. The function may be called from several goroutines at once.
+func (t *Template) WithPreProcess(preProcess func(map[string]interface{}) (map[string]interface{}, error)) *Template {
+	c := t.Clone()
+	c.preProcess = preProcess
+	return c
+}
+
+// Clone returns a copy of t. Since templates are immutable once parsed, a
+// copy is only needed to configure a template differently, which the With
+// methods do.
+func (t *Template) Clone() *Template {
+	c := *t
+	c.parts = append([]templatePart(nil), t.parts...)
+	return &c
+}
+
+// SetPreProcess registers a function that is called with a copy of the
+// values before every expansion. The values it returns are expanded instead,
+// which allows it to add variables derived from others.
+//
+// Deprecated: SetPreProcess modifies a template that may already be shared
+// between goroutines. Use WithPreProcess, which returns a copy.
+func (t *Template) SetPreProcess(preProcess func(map[string]interface{}) (map[string]interface{}, error)) {
+	t.preProcess = preProcess
+}
+
+// truncate shortens s to the maximum length of a prefix modifier, counted in
+// Unicode code points as required by RFC 6570 section 2.4.1, so that a
+// multi-byte character is never split. Where p keeps pct-encoded triplets,
+// a triplet counts as a single character and is never split either.
+func (e *expander) truncate(p *templatePart, term templateTerm, s string) string {
+	if term.truncate <= 0 || len(s) <= term.truncate {
+		return s
+	}
+	triplets := e.keepsTriplets(p)
+	n := 0
+	for i := 0; i < len(s); n++ {
+		if n == term.truncate {
+			truncated := s[:i]
+			if e.template.truncateHandler != nil {
+				e.template.truncateHandler(term.name, s, truncated)
+			}
+			return truncated
+		}
+		if triplets && isTriplet(s, i) {
+			i += 3
+		} else {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+	}
+	return s
+}
+
+func (e *expander) expandNested(t *Template, values map[string]interface{}) (string, error) {
+	max := e.opts.MaxDepth
+	if max <= 0 {
+		max = defaultMaxDepth
+	}
+	if len(e.chain) > max {
+		return "", fmt.Errorf("nested templates exceed depth %d: %s", max, strings.Join(append(e.chain, t.raw), " -> "))
+	}
+	outer := e.template
+	e.template = t
+	e.chain = append(e.chain, t.raw)
+	var buf bytes.Buffer
+	for _, p := range t.parts {
+		if err := p.expand(&buf, values, e); err != nil {
+			return "", err
+		}
+	}
+	e.chain = e.chain[:len(e.chain)-1]
+	e.template = outer
+	return buf.String(), nil
+}
+
+// format returns the string form of a scalar value. Values that implement
+// encoding.TextMarshaler are formatted by MarshalText.
+func (e *expander) format(name string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case time.Time:
+		layout := e.opts.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Format(layout), nil
+	case encoding.TextMarshaler:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			break
+		}
+		text, err := v.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(text), nil
+	}
+	if s, ok := e.opts.Bools.format(value); ok {
+		return s, nil
+	}
+	if s, ok, err := e.opts.Numbers.override(e.opts.NumberFormats[name]).format(value); ok || err != nil {
+		return s, err
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+func (e *expander) read(r io.Reader) (string, error) {
+	max := e.opts.MaxReaderSize
+	if max <= 0 {
+		max = defaultMaxReaderSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(b)) > max {
+		return "", fmt.Errorf("reader value exceeds %d bytes", max)
+	}
+	return string(b), nil
+}
+
+func (e *expander) lookup(values map[string]interface{}, name string) (interface{}, bool, error) {
+	if e.skip[name] {
+		return nil, false, nil
+	}
+	value, exists := values[name]
+	if exists {
+		var err error
+		if value, err = e.resolveLazy(lazyKey{name, false}, value); err != nil {
+			return nil, false, err
+		}
+	}
+	if d, hasDefault := e.opts.Defaults[name]; hasDefault {
+		if _, defined := normalize(value); !exists || !defined {
+			d, err := e.resolveLazy(lazyKey{name, true}, d)
+			return d, err == nil, err
+		}
+	}
+	return value, exists, nil
+}
+
+// writeSep writes the separator between the items of the expression p and,
+// if its pairs are sorted, records where the next one starts.
+func (e *expander) writeSep(buf *bytes.Buffer, p *templatePart) {
+	buf.WriteString(e.sep(p))
+	if e.sortQuery(p) {
+		e.pairs = append(e.pairs, buf.Len())
+	}
+}
+
+func (e *expander) sortQuery(p *templatePart) bool {
+	return (e.opts.SortQuery || e.opts.Escaping == OAuth1) && (p.op == '?' || p.op == '&')
+}
+
+func (e *expander) sep(p *templatePart) string {
+	if len(e.opts.Separator) > 0 {
+		return e.opts.Separator
+	}
+	return p.sep
+}
+
+func (e *expander) escape(p *templatePart, s string) string {
+	if e.opts.Plus == PlusDefault || !strings.Contains(s, "+") {
+		return e.escapeTriplets(p, s)
+	}
+	plus := "+"
+	switch e.opts.Plus {
+	case PlusEncode:
+		plus = string(e.encode([]byte("+")))
+	case PlusSpace:
+		plus = string(e.encode([]byte(" ")))
+	}
+	pieces := strings.Split(s, "+")
+	for i, piece := range pieces {
+		pieces[i] = e.escapeTriplets(p, piece)
+	}
+	return strings.Join(pieces, plus)
+}
+
+// keepsTriplets reports whether pct-encoded triplets in values are kept
+// rather than encoded again. RFC 6570 passes them through unchanged in
+// reserved and fragment expansions; NormalizePercent keeps them everywhere.
+func (e *expander) keepsTriplets(p *templatePart) bool {
+	return e.opts.NormalizePercent || p.allowReserved && e.opts.Escaping != OAuth1
+}
+
+func (e *expander) escapeTriplets(p *templatePart, s string) string {
+	if !e.keepsTriplets(p) || !strings.Contains(s, "%") {
+		return e.escapeBytes(p, s)
+	}
+	var out strings.Builder
+	last := 0
+	for i := 0; i+2 < len(s); i++ {
+		if !isTriplet(s, i) {
+			continue
+		}
+		out.WriteString(e.escapeBytes(p, s[last:i]))
+		if e.opts.NormalizePercent {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				out.WriteByte(c)
+			} else {
+				out.Write(e.encode([]byte{c}))
+			}
+		} else {
+			out.WriteString(s[i : i+3])
+		}
+		i += 2
+		last = i + 1
+	}
+	out.WriteString(e.escapeBytes(p, s[last:]))
+	return out.String()
+}
+
+func (e *expander) escapeBytes(p *templatePart, s string) string {
+	if isUnreservedString(s) {
+		return s
+	}
+	set := unreserved
+	if p.op == '#' && e.opts.Escaping == FragmentSafe {
+		set = fragment
+	} else if p.allowReserved && e.opts.Escaping == StrictReserved {
+		set = strictreserved
+	} else if p.allowReserved && e.opts.Escaping != OAuth1 {
+		set = reserved
+	} else if e.opts.Escaping == PathSafe {
+		set = pathsafe
+	}
+	return string(appendEscaped(make([]byte, 0, len(s)+16), s, set, e.appendEncoded))
+}
+
+func (e *expander) encode(src []byte) []byte {
+	return e.appendEncoded(nil, string(src))
+}
+
+// appendEncoded appends the percent-encoding of the character src to dst,
+// or src itself if it may appear unencoded in an IRI and IRI is set.
+func (e *expander) appendEncoded(dst []byte, src string) []byte {
+	if e.opts.IRI && e.opts.Escaping != OAuth1 && src[0] >= utf8.RuneSelf {
+		if r, size := utf8.DecodeRuneInString(src); size == len(src) && isUcschar(r) {
+			return append(dst, src...)
+		}
+	}
+	if e.opts.LowerHex && e.opts.Escaping != OAuth1 {
+		return appendPctEncoded(dst, src, lowerhex)
+	}
+	return appendPctEncoded(dst, src, hex)
+}
+
+func isUnreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isUnreservedString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isUnreserved(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTriplet reports whether a pct-encoded triplet starts at s[i].
+func isTriplet(s string, i int) bool {
+	return i+2 < len(s) && s[i] == '%' && ishex(s[i+1]) && ishex(s[i+2])
+}
+
+func ishex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// sortPairs sorts the name=value pairs written to buf after start by name,
+// then by value, and joins them with sep again. Each offset in starts is
+// where a pair other than the first begins, after the sep before it; the
+// pairs are not found by splitting on sep, which may be part of a value.
+func sortPairs(buf *bytes.Buffer, start int, sep string, starts []int) {
+	if buf.Len() == start || len(starts) == 0 {
+		return
+	}
+	b := buf.Bytes()
+	pairs := make([]string, 0, len(starts)+1)
+	from := start
+	for _, next := range starts {
+		pairs = append(pairs, string(b[from:next-len(sep)]))
+		from = next
+	}
+	pairs = append(pairs, string(b[from:]))
+	sort.SliceStable(pairs, func(i, j int) bool {
+		ki, vi := splitPair(pairs[i])
+		kj, vj := splitPair(pairs[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return vi < vj
+	})
+	buf.Truncate(start)
+	buf.WriteString(strings.Join(pairs, sep))
+}
+
+func splitPair(pair string) (string, string) {
+	if i := strings.IndexByte(pair, '='); i >= 0 {
+		return pair[:i], pair[i+1:]
+	}
+	return pair, ""
+}
+
+// An ExpandOption sets an option of ExpandWith.
+type ExpandOption func(*ExpandOpts)
+
+// ExpandWith expands a URI template like Expand, with the settings of opts
+// applied in order to the zero ExpandOpts:
+//
+//	t.ExpandWith(values, uri.WithSortQuery(), uri.WithDefaults(map[string]interface{}{"page": 1}))
+func (t *Template) ExpandWith(value interface{}, opts ...ExpandOption) (string, error) {
+	var o ExpandOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return t.ExpandWithOpts(value, o)
+}
+
+// WithOpts replaces all settings with opts. Options that follow it change
+// individual settings of opts.
+func WithOpts(opts ExpandOpts) ExpandOption {
+	return func(o *ExpandOpts) { *o = opts }
+}
+
+// WithStrict sets ExpandOpts.Strict.
+func WithStrict() ExpandOption {
+	return func(o *ExpandOpts) { o.Strict = true }
+}
+
+// WithEscaping sets ExpandOpts.Escaping.
+func WithEscaping(escaping Escaping) ExpandOption {
+	return func(o *ExpandOpts) { o.Escaping = escaping }
+}
+
+// WithSeparator sets ExpandOpts.Separator.
+func WithSeparator(sep string) ExpandOption {
+	return func(o *ExpandOpts) { o.Separator = sep }
+}
+
+// WithIRI sets ExpandOpts.IRI.
+func WithIRI() ExpandOption {
+	return func(o *ExpandOpts) { o.IRI = true }
+}
+
+// WithIDNA sets ExpandOpts.IDNA.
+func WithIDNA() ExpandOption {
+	return func(o *ExpandOpts) { o.IDNA = true }
+}
+
+// WithLowerHex sets ExpandOpts.LowerHex.
+func WithLowerHex() ExpandOption {
+	return func(o *ExpandOpts) { o.LowerHex = true }
+}
+
+// WithSortQuery sets ExpandOpts.SortQuery.
+func WithSortQuery() ExpandOption {
+	return func(o *ExpandOpts) { o.SortQuery = true }
+}
+
+// WithJSONTags sets ExpandOpts.JSONTags.
+func WithJSONTags() ExpandOption {
+	return func(o *ExpandOpts) { o.JSONTags = true }
+}
+
+// WithNormalizePercent sets ExpandOpts.NormalizePercent.
+func WithNormalizePercent() ExpandOption {
+	return func(o *ExpandOpts) { o.NormalizePercent = true }
+}
+
+// WithDefaults adds defaults to ExpandOpts.Defaults, replacing earlier
+// defaults for the same variables. The map is copied, not modified.
+func WithDefaults(defaults map[string]interface{}) ExpandOption {
+	return func(o *ExpandOpts) {
+		merged := make(map[string]interface{}, len(o.Defaults)+len(defaults))
+		for k, v := range o.Defaults {
+			merged[k] = v
+		}
+		for k, v := range defaults {
+			merged[k] = v
+		}
+		o.Defaults = merged
+	}
+}
+
+// WithEncoder sets the encoder of the variable name in ExpandOpts.Encoders.
+func WithEncoder(name string, encode func(v interface{}) (string, error)) ExpandOption {
+	return func(o *ExpandOpts) {
+		encoders := make(map[string]func(interface{}) (string, error), len(o.Encoders)+1)
+		for k, v := range o.Encoders {
+			encoders[k] = v
+		}
+		encoders[name] = encode
+		o.Encoders = encoders
+	}
+}
+
+// WithHook appends hook to ExpandOpts.Hooks.
+func WithHook(hook ExpandHook) ExpandOption {
+	return func(o *ExpandOpts) {
+		o.Hooks = append(o.Hooks[:len(o.Hooks):len(o.Hooks)], hook)
+	}
+}
+
+// WithLegacyNil sets ExpandOpts.LegacyNil.
+func WithLegacyNil() ExpandOption {
+	return func(o *ExpandOpts) { o.LegacyNil = true }
+}
+
+// WithPlus sets ExpandOpts.Plus.
+func WithPlus(mode PlusMode) ExpandOption {
+	return func(o *ExpandOpts) { o.Plus = mode }
+}
+
+// WithNull sets ExpandOpts.Null.
+func WithNull(mode NullMode) ExpandOption {
+	return func(o *ExpandOpts) { o.Null = mode }
+}
+
+// WithNameSeparator sets ExpandOpts.NameSeparator.
+func WithNameSeparator(sep string) ExpandOption {
+	return func(o *ExpandOpts) { o.NameSeparator = sep }
+}
+
+// WithTimeLayout sets ExpandOpts.TimeLayout.
+func WithTimeLayout(layout string) ExpandOption {
+	return func(o *ExpandOpts) { o.TimeLayout = layout }
+}
+
+// WithNumbers sets ExpandOpts.Numbers.
+func WithNumbers(format NumberFormat) ExpandOption {
+	return func(o *ExpandOpts) { o.Numbers = format }
+}
+
+// WithNumberFormat sets the number format of the variable name in
+// ExpandOpts.NumberFormats.
+func WithNumberFormat(name string, format NumberFormat) ExpandOption {
+	return func(o *ExpandOpts) {
+		formats := make(map[string]NumberFormat, len(o.NumberFormats)+1)
+		for k, v := range o.NumberFormats {
+			formats[k] = v
+		}
+		formats[name] = format
+		o.NumberFormats = formats
+	}
+}
+
+// WithBools sets ExpandOpts.Bools.
+func WithBools(format BoolFormat) ExpandOption {
+	return func(o *ExpandOpts) { o.Bools = format }
+}
+
+// WithMaxDepth sets ExpandOpts.MaxDepth.
+func WithMaxDepth(depth int) ExpandOption {
+	return func(o *ExpandOpts) { o.MaxDepth = depth }
+}
+
+// WithMaxReaderSize sets ExpandOpts.MaxReaderSize.
+func WithMaxReaderSize(size int64) ExpandOption {
+	return func(o *ExpandOpts) { o.MaxReaderSize = size }
+}
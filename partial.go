@@ -0,0 +1,105 @@
+package uri
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExpandPartial expands the variables of the template that value defines
+// and keeps expressions for the others, including those set to nil or to
+// an empty list or map, producing a template that can be parsed and
+// expanded again with the remaining values.
+//
+// Expressions with "/", "." and ";" operators are split into one expression
+// per undefined variable. In query expressions the expanded parameters are
+// placed before those that remain, as "?a=1{&b}". Simple, reserved ("+") and
+// fragment ("#") expressions that mix defined and undefined variables cannot
+// be represented and cause an error.
+func (t *Template) ExpandPartial(value interface{}) (string, error) {
+	e := &expander{opts: &ExpandOpts{}, template: t, chain: []string{t.raw}}
+	values, err := t.values(value, e.opts)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, p := range t.parts {
+		if p.terms == nil {
+			buf.WriteString(p.raw)
+			continue
+		}
+		var defined, undefined []templateTerm
+		isDefined := make(map[string]bool)
+		for _, term := range p.terms {
+			ok, err := e.defined(values, term.name)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				defined = append(defined, term)
+				isDefined[term.name] = true
+			} else {
+				undefined = append(undefined, term)
+			}
+		}
+		if len(undefined) == 0 {
+			if err := p.expand(&buf, values, e); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if len(defined) == 0 {
+			buf.WriteString("{" + p.expr + "}")
+			continue
+		}
+		switch p.op {
+		case '?', '&':
+			sub := p
+			sub.terms = defined
+			start := buf.Len()
+			if err := sub.expand(&buf, values, e); err != nil {
+				return "", err
+			}
+			op := "&"
+			if p.op == '?' && buf.Len() == start {
+				op = "?"
+			}
+			buf.WriteString("{" + op + joinTerms(undefined) + "}")
+		case '/', '.', ';':
+			for _, term := range p.terms {
+				sub := p
+				sub.terms = []templateTerm{term}
+				if isDefined[term.name] {
+					if err := sub.expand(&buf, values, e); err != nil {
+						return "", err
+					}
+				} else {
+					buf.WriteString("{" + string(p.op) + term.String() + "}")
+				}
+			}
+		default:
+			return "", fmt.Errorf("cannot partially expand {%s}", p.expr)
+		}
+	}
+	return buf.String(), nil
+}
+
+// String returns the term as it is written in a template.
+func (term templateTerm) String() string {
+	if term.explode {
+		return term.name + "*"
+	}
+	if term.truncate > 0 {
+		return term.name + ":" + strconv.Itoa(term.truncate)
+	}
+	return term.name
+}
+
+func joinTerms(terms []templateTerm) string {
+	s := make([]string, len(terms))
+	for i, term := range terms {
+		s[i] = term.String()
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,70 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpandPartial(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+		ok   bool
+	}{
+		{"http://localhost:8080/{id}", map[string]interface{}{"id": "foo"}, "http://localhost:8080/foo", true},
+		{"http://localhost:8080/{id}", map[string]interface{}{}, "http://localhost:8080/{id}", true},
+		{"{+base}/items/{id}{?q,limit:3}", map[string]interface{}{"base": "http://example.com"}, "http://example.com/items/{id}{?q,limit:3}", true},
+		{"/items{?q,limit,page}", map[string]interface{}{"limit": 10}, "/items?limit=10{&q,page}", true},
+		{"/items{?q,limit}", map[string]interface{}{"q": "a b"}, "/items?q=a%20b{&limit}", true},
+		{"/items{?q,list*}", map[string]interface{}{"q": []interface{}{}}, "/items{?q,list*}", true},
+		{"/items{?page}", map[string]interface{}{"page": nil}, "/items{?page}", true},
+		{"/items{?page}", map[string]interface{}{"page": []string{}}, "/items{?page}", true},
+		{"/items{?page,q}", map[string]interface{}{"page": (*int)(nil), "q": "x"}, "/items?q=x{&page}", true},
+		{"/repos{/user,repo}", map[string]interface{}{"user": map[string]string{}, "repo": "r"}, "/repos{/user}/r", true},
+		{"/items?x=1{&q,limit}", map[string]interface{}{"limit": 10}, "/items?x=1&limit=10{&q}", true},
+		{"/repos{/user,repo,branch}", map[string]interface{}{"user": "u", "branch": "main"}, "/repos/u{/repo}/main", true},
+		{"/file{.name,ext}", map[string]interface{}{"ext": "json"}, "/file{.name}.json", true},
+		{"/map{;x,y}", map[string]interface{}{"x": 1}, "/map;x=1{;y}", true},
+		{"/{a,b}", map[string]interface{}{"a": 1}, "", false},
+		{"/{+a,b}", map[string]interface{}{"a": 1}, "", false},
+		{"/{a}{#b}", map[string]interface{}{"a": "{x}"}, "/%7Bx%7D{#b}", true},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandPartial(test.args)
+			if (err == nil) != test.ok {
+				t.Fatalf("want ok %t, got error %v", test.ok, err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+			if !test.ok {
+				return
+			}
+			if _, err := Parse(out); err != nil {
+				t.Errorf("partial expansion is not a valid template: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpandPartialStages(t *testing.T) {
+	template := MustParse("{+base}/repos{/user,repo}{?page,limit}")
+	partial, err := template.ExpandPartial(map[string]interface{}{"base": "https://example.com", "repo": "r", "limit": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := MustParse(partial).Expand(map[string]interface{}{"user": "u", "page": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/repos/u/r?limit=10&page=2"; want != out {
+		t.Errorf("want %s, got %s", want, out)
+	}
+}
@@ -0,0 +1,69 @@
+package uri
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// valueSizeHint is the expected size of an expanded variable.
+	valueSizeHint = 16
+	// maxPooledBuffer is the capacity above which buffers are not reused,
+	// so that a rare large expansion does not pin its memory in the pool.
+	maxPooledBuffer = 64 << 10
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool with room for size bytes.
+func getBuffer(size int) *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Grow(size)
+	return buf
+}
+
+// putBuffer returns buf to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+var expanderPool = sync.Pool{
+	New: func() interface{} { return new(expander) },
+}
+
+// noOpts are the options of expansions without options. Expanders never
+// modify their options, so they are shared.
+var noOpts ExpandOpts
+
+// getExpander returns an expander from the pool that expands with opts.
+func getExpander(opts *ExpandOpts) *expander {
+	e := expanderPool.Get().(*expander)
+	e.opts = opts
+	return e
+}
+
+// putExpander clears e, keeping the capacity of its slices, and returns it
+// to the pool.
+func putExpander(e *expander) {
+	for i := range e.chain {
+		e.chain[i] = ""
+	}
+	*e = expander{chain: e.chain[:0], pairs: e.pairs[:0]}
+	expanderPool.Put(e)
+}
+
+// sizeHint estimates the length of an expansion of t: its literals plus
+// valueSizeHint bytes for every variable.
+func (t *Template) sizeHint() int {
+	n := len(t.raw)
+	for _, p := range t.parts {
+		n += len(p.terms) * valueSizeHint
+	}
+	return n
+}
@@ -0,0 +1,43 @@
+package uri
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBufferPool(t *testing.T) {
+	buf := getBuffer(100)
+	if buf.Len() != 0 || buf.Cap() < 100 {
+		t.Errorf("want an empty buffer with room for 100 bytes, got len %d, cap %d", buf.Len(), buf.Cap())
+	}
+	buf.WriteString("leftover")
+	putBuffer(buf)
+	if buf := getBuffer(0); buf.Len() != 0 {
+		t.Errorf("want an empty buffer, got %q", buf.String())
+	}
+	if hint := MustParse("/items{/id}{?q,p}").sizeHint(); hint != len("/items{/id}{?q,p}")+3*valueSizeHint {
+		t.Errorf("unexpected size hint %d", hint)
+	}
+}
+
+func TestExpandPooledConcurrent(t *testing.T) {
+	template := MustParse("/items{/id}{?q}")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				q := strings.Repeat("x", j)
+				want := fmt.Sprintf("/items/%d?q=%s", i, q)
+				if got, err := template.Expand(map[string]interface{}{"id": i, "q": q}); err != nil || got != want {
+					t.Errorf("want %s, got %s, %v", want, got, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
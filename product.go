@@ -0,0 +1,67 @@
+package uri
+
+import "fmt"
+
+// maxProduct limits the number of expansions ExpandProduct produces.
+const maxProduct = 10000
+
+// ExpandProduct expands the template once for every combination of the
+// elements of the list values referenced by the template, substituting a
+// single element of each list per expansion. Other values are the same in
+// every expansion. Combinations are ordered by the template order of their
+// variables, with the last variable varying fastest. An error is returned if
+// more than 10000 expansions would be produced.
+func (t *Template) ExpandProduct(values map[string]interface{}) ([]string, error) {
+	var names []string
+	var dimensions [][]interface{}
+	size := 1
+	seen := make(map[string]bool)
+	for _, p := range t.parts {
+		for _, term := range p.terms {
+			if seen[term.name] {
+				continue
+			}
+			seen[term.name] = true
+			value, exists := values[term.name]
+			if !exists {
+				continue
+			}
+			value, _ = normalize(value)
+			if list, isList := value.([]interface{}); isList && len(list) > 0 {
+				names = append(names, term.name)
+				dimensions = append(dimensions, list)
+				size *= len(list)
+				if size > maxProduct {
+					return nil, fmt.Errorf("product of list values exceeds %d expansions", maxProduct)
+				}
+			}
+		}
+	}
+	combination := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		combination[k] = v
+	}
+	results := make([]string, 0, size)
+	indices := make([]int, len(dimensions))
+	for {
+		for i, name := range names {
+			combination[name] = dimensions[i][indices[i]]
+		}
+		expanded, err := t.Expand(combination)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, expanded)
+		i := len(indices) - 1
+		for ; i >= 0; i-- {
+			indices[i]++
+			if indices[i] < len(dimensions[i]) {
+				break
+			}
+			indices[i] = 0
+		}
+		if i < 0 {
+			return results, nil
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExpandProduct(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  []string
+	}{
+		{"{region}/{service}", map[string]interface{}{"region": []interface{}{"us", "eu"}, "service": []interface{}{"a", "b"}}, []string{"us/a", "us/b", "eu/a", "eu/b"}},
+		{"/{env}/{region}/{service}", map[string]interface{}{"env": "prod", "region": []string{"us", "eu"}, "service": "a"}, []string{"/prod/us/a", "/prod/eu/a"}},
+		{"/{env}{?region}", map[string]interface{}{"env": "prod", "unused": []interface{}{"x", "y"}}, []string{"/prod"}},
+		{"/{region}/{region}", map[string]interface{}{"region": []interface{}{"us", "eu"}}, []string{"/us/us", "/eu/eu"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandProduct(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.out, out) {
+				t.Errorf("want %v, got %v", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandProductLimit(t *testing.T) {
+	template, err := Parse("{a}{b}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := make([]int, 101)
+	if _, err := template.ExpandProduct(map[string]interface{}{"a": list, "b": list[:99]}); err != nil {
+		t.Errorf("want %d expansions to be allowed, got %v", 101*99, err)
+	}
+	if _, err := template.ExpandProduct(map[string]interface{}{"a": list, "b": list}); err == nil {
+		t.Errorf("want an error for %d expansions", 101*101)
+	}
+}
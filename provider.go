@@ -0,0 +1,75 @@
+package uri
+
+import (
+	"context"
+	"strings"
+)
+
+// A ValueProvider looks up the values of variables on demand, such as from
+// a request-scoped store, a secrets manager or a database. Lookup returns
+// the value of the variable name and whether it is defined, or an error if
+// the lookup failed.
+type ValueProvider interface {
+	Lookup(ctx context.Context, name string) (interface{}, bool, error)
+}
+
+// ValueProviderFunc adapts a function to the ValueProvider interface.
+type ValueProviderFunc func(ctx context.Context, name string) (interface{}, bool, error)
+
+// Lookup calls f(ctx, name).
+func (f ValueProviderFunc) Lookup(ctx context.Context, name string) (interface{}, bool, error) {
+	return f(ctx, name)
+}
+
+// A LookupError reports that a ValueProvider failed to look up a variable.
+type LookupError struct {
+	Name string
+	Err  error
+}
+
+func (e *LookupError) Error() string {
+	return "lookup " + e.Name + ": " + e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// ExpandContext expands the template like Expand with the values that
+// provider returns for its variables. Each variable is looked up once, in
+// order of first appearance, and the expansion stops with the error of ctx
+// once it is done, or with a *LookupError if a lookup fails. In a dotted
+// template, provider is asked for the first element of each field path,
+// such as "User" for "{.User.Name}", and the rest of the path is resolved
+// in the value it returns. The provider is not asked for the root path
+// "{.}", which refers to the values returned for the other paths.
+func (t *Template) ExpandContext(ctx context.Context, provider ValueProvider) (string, error) {
+	values := make(map[string]interface{})
+	looked := make(map[string]bool)
+	for _, name := range t.Names() {
+		if t.dotted {
+			name = strings.SplitN(strings.TrimPrefix(name, "."), ".", 2)[0]
+			if name == "" {
+				continue
+			}
+		}
+		if looked[name] {
+			continue
+		}
+		looked[name] = true
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		v, exists, err := provider.Lookup(ctx, name)
+		if err != nil {
+			return "", &LookupError{Name: name, Err: err}
+		}
+		if exists {
+			values[name] = v
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return t.Expand(values)
+}
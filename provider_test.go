@@ -0,0 +1,83 @@
+package uri
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExpandContext(t *testing.T) {
+	values := map[string]interface{}{"id": 42, "q": "a b", "tags": []string{"x", "y"}}
+	var lookups []string
+	provider := ValueProviderFunc(func(ctx context.Context, name string) (interface{}, bool, error) {
+		lookups = append(lookups, name)
+		v, ok := values[name]
+		return v, ok, nil
+	})
+	actual, err := MustParse("/items/{id}{?q,missing,tags}{&id}").ExpandContext(context.Background(), provider)
+	if expected := "/items/42?q=a%20b&tags=x,y&id=42"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+	if len(lookups) != 4 {
+		t.Errorf("expected each variable to be looked up once, got %v", lookups)
+	}
+}
+
+func TestExpandContextDotted(t *testing.T) {
+	template, err := ParseDotted("/users/{.User.ID}{..Ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := ValueProviderFunc(func(ctx context.Context, name string) (interface{}, bool, error) {
+		switch name {
+		case "User":
+			return map[string]interface{}{"ID": 7}, true, nil
+		case "Ext":
+			return "json", true, nil
+		}
+		return nil, false, nil
+	})
+	actual, err := template.ExpandContext(context.Background(), provider)
+	if expected := "/users/7.json"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+}
+
+func TestExpandContextError(t *testing.T) {
+	errSecret := errors.New("secret unavailable")
+	provider := ValueProviderFunc(func(ctx context.Context, name string) (interface{}, bool, error) {
+		if name == "token" {
+			return nil, false, errSecret
+		}
+		return "v", true, nil
+	})
+	template := MustParse("/api/{id}{?token}")
+	_, err := template.ExpandContext(context.Background(), provider)
+	var lerr *LookupError
+	if !errors.As(err, &lerr) || lerr.Name != "token" || !errors.Is(err, errSecret) {
+		t.Errorf("expected lookup error for token, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := template.ExpandContext(ctx, provider); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestExpandContextDottedRoot(t *testing.T) {
+	template, err := ParseDotted("/u{/.}{?.User}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := ValueProviderFunc(func(ctx context.Context, name string) (interface{}, bool, error) {
+		if name != "User" {
+			t.Errorf("unexpected lookup of %q", name)
+		}
+		return "bob", true, nil
+	})
+	actual, err := template.ExpandContext(context.Background(), provider)
+	if expected := "/u/User,bob?.User=bob"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+}
@@ -0,0 +1,314 @@
+package uri
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var queryTemplate = MustParse("{?query*}")
+
+// EncodeQuery encodes value as a query string, without the leading "?". The
+// value is a struct, a pointer to one, or a map with string keys, as for
+// Template.Expand, and struct fields are named by the same uri tags. Each
+// variable is encoded as by an exploded "{?name*}" expression: a list
+// becomes one parameter per element, a map one parameter per entry, and a
+// nested struct one parameter per field named by its path, such as
+// "user.name". Nil pointers are left out like undefined variables.
+func EncodeQuery(value interface{}) (string, error) {
+	normalized, _ := normalize(value)
+	var pairs Ordered
+	switch v := normalized.(type) {
+	case map[string]interface{}:
+		pairs = mapPairs(v)
+	case Ordered:
+		pairs = v
+	default:
+		var isStruct bool
+		if pairs, isStruct = struct2pairs(value, &ExpandOpts{}); !isStruct {
+			return "", errors.New("expected map with string keys, struct, or pointer to struct.")
+		}
+	}
+	params := queryParams(nil, "", pairs, 0)
+	if len(params) == 0 {
+		return "", nil
+	}
+	query, err := queryTemplate.Expand(map[string]interface{}{"query": params})
+	if err != nil {
+		return "", err
+	}
+	return query[1:], nil
+}
+
+// queryParams appends to dst the parameters that encode pairs, with names
+// prefixed by prefix.
+func queryParams(dst Ordered, prefix string, pairs Ordered, depth int) Ordered {
+	if depth >= defaultMaxDepth {
+		return dst
+	}
+	for _, kv := range pairs {
+		name := prefix + kv.Key
+		value, defined := normalize(kv.Value)
+		if !defined || value == nil || value == Null {
+			continue
+		}
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+			continue
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			for _, element := range v {
+				dst = append(dst, Pair{name, element})
+			}
+		case map[string]interface{}:
+			dst = queryParams(dst, "", mapPairs(v), depth+1)
+		case Ordered:
+			dst = queryParams(dst, "", v, depth+1)
+		default:
+			if fields, isStruct := struct2pairs(v, &ExpandOpts{}); isStruct && !isScalar(v) {
+				dst = queryParams(dst, name+".", fields, depth+1)
+			} else {
+				dst = append(dst, Pair{name, v})
+			}
+		}
+	}
+	return dst
+}
+
+// DecodeQuery decodes the query string query, with or without a leading
+// "?", into the struct that dst points to. Fields are named by their uri
+// tags as for EncodeQuery and are decoded from the conventions it and
+// Template.Expand use: a slice field collects every parameter of its name
+// and splits their values at commas, a nested struct field is decoded from
+// parameters named by its path, such as "user.name", and a map field with
+// string keys collects the parameters that no other field uses, as produced
+// by an exploded map. Fields of type time.Time are parsed with the layout
+// of their tag or as RFC 3339, and fields that implement
+// encoding.TextUnmarshaler by UnmarshalText.
+func DecodeQuery(query string, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("expected non-nil pointer to struct")
+	}
+	params := make(map[string][]string)
+	var names []string
+	for _, param := range strings.Split(strings.TrimPrefix(query, "?"), "&") {
+		if param == "" {
+			continue
+		}
+		name, value := param, ""
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name, value = param[:i], param[i+1:]
+		}
+		name, err := url.PathUnescape(name)
+		if err != nil {
+			return err
+		}
+		if _, exists := params[name]; !exists {
+			names = append(names, name)
+		}
+		params[name] = append(params[name], value)
+	}
+	d := &queryDecoder{params: params, names: names, used: make(map[string]bool)}
+	if err := d.decodeStruct(v.Elem(), "", 0); err != nil {
+		return err
+	}
+	for _, m := range d.maps {
+		if err := d.decodeMap(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type queryDecoder struct {
+	params map[string][]string
+	names  []string
+	used   map[string]bool
+	maps   []reflect.Value
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func (d *queryDecoder) decodeStruct(v reflect.Value, prefix string, depth int) error {
+	if depth >= defaultMaxDepth {
+		return nil
+	}
+	for _, f := range cachedFields(v.Type(), false) {
+		name := prefix + f.name
+		field := v.Field(f.index)
+		t := field.Type()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch {
+		case isDecodable(t):
+		case t.Kind() == reflect.Struct:
+			if !d.hasPrefix(name + ".") {
+				continue
+			}
+			if field.Kind() == reflect.Ptr && field.IsNil() {
+				field.Set(reflect.New(t))
+			}
+			if err := d.decodeStruct(reflect.Indirect(field), name+".", depth+1); err != nil {
+				return err
+			}
+			continue
+		case field.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+			d.maps = append(d.maps, field)
+			continue
+		}
+		raws, exists := d.params[name]
+		if !exists {
+			continue
+		}
+		d.used[name] = true
+		if t.Kind() == reflect.Slice && !isDecodable(t) {
+			if field.Kind() == reflect.Ptr {
+				field.Set(reflect.New(t))
+				field = field.Elem()
+			}
+			if err := decodeList(field, raws, f.layout); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		s, err := url.PathUnescape(raws[len(raws)-1])
+		if err != nil {
+			return err
+		}
+		if err := decodeValue(field, s, f.layout); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (d *queryDecoder) hasPrefix(prefix string) bool {
+	for _, name := range d.names {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeMap stores the parameters that no field uses in the map field m.
+func (d *queryDecoder) decodeMap(m reflect.Value) error {
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	for _, name := range d.names {
+		if d.used[name] {
+			continue
+		}
+		raws := d.params[name]
+		element := reflect.New(m.Type().Elem()).Elem()
+		var err error
+		if element.Kind() == reflect.Slice && !isDecodable(element.Type()) {
+			err = decodeList(element, raws, "")
+		} else {
+			var s string
+			if s, err = url.PathUnescape(raws[len(raws)-1]); err == nil {
+				err = decodeValue(element, s, "")
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(name).Convert(m.Type().Key()), element)
+	}
+	return nil
+}
+
+// decodeList sets the slice v to the elements of the raw parameter values,
+// split at commas.
+func decodeList(v reflect.Value, raws []string, layout string) error {
+	list := reflect.MakeSlice(v.Type(), 0, len(raws))
+	for _, raw := range raws {
+		for _, piece := range strings.Split(raw, ",") {
+			s, err := url.PathUnescape(piece)
+			if err != nil {
+				return err
+			}
+			element := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(element, s, layout); err != nil {
+				return err
+			}
+			list = reflect.Append(list, element)
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+// isDecodable reports whether a value of type t is decoded from a single
+// string rather than from several parameters.
+func isDecodable(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Time{}) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// decodeValue sets v from the string s.
+func decodeValue(v reflect.Value, s, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if t, isTime := v.Addr().Interface().(*time.Time); isTime {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		parsed, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+	if u, isText := v.Addr().Interface().(encoding.TextUnmarshaler); isText {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("cannot decode into %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("cannot decode into %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type queryUser struct {
+	Name string `uri:"name"`
+	ID   int    `uri:"id"`
+}
+
+type queryParamsTest struct {
+	Q      string            `uri:"q"`
+	Page   int               `uri:"page,omitempty"`
+	Tags   []string          `uri:"tags"`
+	IDs    *[]int            `uri:"ids"`
+	Exact  *bool             `uri:"exact"`
+	Since  time.Time         `uri:"since,omitempty,layout=2006-01-02"`
+	Color  *textColorValue   `uri:"color"`
+	User   queryUser         `uri:"user"`
+	Owner  *queryUser        `uri:"owner"`
+	Secret string            `uri:"-"`
+	Extra  map[string]string `uri:"extra"`
+}
+
+type textColorValue struct {
+	R, G, B uint8
+}
+
+func (c *textColorValue) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return err
+}
+
+func (c *textColorValue) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)), nil
+}
+
+func TestEncodeQuery(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		out   string
+	}{
+		{map[string]interface{}{"b": "x y", "a": []int{1, 2}}, "a=1&a=2&b=x%20y"},
+		{map[string]interface{}{"filter": map[string]string{"min": "1", "max": "9"}}, "max=9&min=1"},
+		{Ordered{{"z", 1}, {"a", 2}}, "z=1&a=2"},
+		{queryUser{"ann", 7}, "name=ann&id=7"},
+		{&queryParamsTest{Q: "go", Tags: []string{"a", "b"}, User: queryUser{Name: "ann"}, Color: &textColorValue{255, 0, 0}, Secret: "s"},
+			"q=go&tags=a&tags=b&color=%23ff0000&user.name=ann&user.id=0"},
+		{map[string]interface{}{}, ""},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := EncodeQuery(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+	if _, err := EncodeQuery(42); err == nil {
+		t.Error("want error for an int")
+	}
+}
+
+func TestDecodeQuery(t *testing.T) {
+	var got queryParamsTest
+	query := "?q=a%20b&page=2&tags=x&tags=y,z%2Cw&ids=1,2&exact=true&since=2017-07-13&color=%2300ff10&user.name=ann&user.id=7&owner.name=bob&Secret=s&foo=1&bar=%2F"
+	if err := DecodeQuery(query, &got); err != nil {
+		t.Fatal(err)
+	}
+	exact := true
+	ids := []int{1, 2}
+	want := queryParamsTest{
+		Q:     "a b",
+		Page:  2,
+		Tags:  []string{"x", "y", "z,w"},
+		IDs:   &ids,
+		Exact: &exact,
+		Since: time.Date(2017, 7, 13, 0, 0, 0, 0, time.UTC),
+		Color: &textColorValue{0, 255, 16},
+		User:  queryUser{"ann", 7},
+		Owner: &queryUser{Name: "bob"},
+		Extra: map[string]string{"Secret": "s", "foo": "1", "bar": "/"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestQueryRoundTrip(t *testing.T) {
+	in := queryParamsTest{Q: "a&b=c", Page: 3, Tags: []string{"x,y", "z"}, User: queryUser{"ann", 7}}
+	query, err := EncodeQuery(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out queryParamsTest
+	if err := DecodeQuery(query, &out); err != nil {
+		t.Fatal(err)
+	}
+	out.Extra = nil
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("%s: want %+v, got %+v", query, in, out)
+	}
+}
+
+func TestDecodeQueryErrors(t *testing.T) {
+	var v queryParamsTest
+	tests := []struct {
+		query string
+		dst   interface{}
+		err   string
+	}{
+		{"page=x", &v, "page:"},
+		{"ids=1,x", &v, "ids:"},
+		{"since=yesterday", &v, "since:"},
+		{"q=%zz", &v, "invalid URL escape"},
+		{"q=a", v, "expected non-nil pointer to struct"},
+		{"q=a", (*queryParamsTest)(nil), "expected non-nil pointer to struct"},
+	}
+	for _, test := range tests {
+		err := DecodeQuery(test.query, test.dst)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: want error containing %q, got %v", test.query, test.err, err)
+		}
+	}
+}
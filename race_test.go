@@ -0,0 +1,7 @@
+//go:build race
+
+package uri
+
+// raceEnabled reports whether the race detector is enabled, which makes
+// sync.Pool drop items at random and allocation counts unreliable.
+const raceEnabled = true
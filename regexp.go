@@ -0,0 +1,116 @@
+package uri
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var groupname = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// Regexp returns an anchored regular expression that matches the expansions
+// of the template, with one capture group per variable, and the names of
+// the variables in the order of their groups: names[i] is captured by
+// subexpression i+1. Groups are named after their variables where regexp
+// syntax allows, and "v1", "v2" and so on otherwise.
+//
+// The captured text is not percent-decoded. An exploded variable captures
+// its whole expansion, and query parameters must appear in template order.
+// Use Match to decode values and to accept parameters in any order.
+func (t *Template) Regexp() (*regexp.Regexp, []string) {
+	var b strings.Builder
+	var names []string
+	used := make(map[string]bool)
+	group := func(name, class string) string {
+		names = append(names, name)
+		group := name
+		if !groupname.MatchString(name) || used[name] {
+			group = "v" + strconv.Itoa(len(names))
+		}
+		used[group] = true
+		return "(?P<" + group + ">" + class + ")"
+	}
+	b.WriteString("^")
+	for _, p := range t.parts {
+		if p.terms == nil {
+			b.WriteString(quoteLiteral(p.raw))
+			continue
+		}
+		for i, term := range p.terms {
+			class := p.class()
+			if term.explode {
+				class = p.explodeClass()
+			}
+			prefix := regexp.QuoteMeta(p.sep)
+			if i == 0 {
+				prefix = regexp.QuoteMeta(p.first)
+			}
+			switch {
+			case p.named:
+				if p.first != p.sep {
+					prefix = "[" + regexp.QuoteMeta(p.first+p.sep) + "]"
+				}
+				if term.explode {
+					b.WriteString("(?:" + prefix + group(term.name, class) + ")?")
+				} else {
+					b.WriteString("(?:" + prefix + regexp.QuoteMeta(term.name) + "(?:=" + group(term.name, class) + ")?)?")
+				}
+			case i == 0 && p.first == "":
+				b.WriteString(group(term.name, class))
+			default:
+				b.WriteString("(?:" + prefix + group(term.name, class) + ")?")
+			}
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), names
+}
+
+// class returns the character class of a single value in an expansion of
+// the expression.
+func (p *templatePart) class() string {
+	switch p.op {
+	case '+', '#':
+		return "[^,]*"
+	case '.':
+		return "[^/?#.]*"
+	case '/':
+		return "[^/?#]*"
+	case ';':
+		return "[^;/?#]*"
+	case '?', '&':
+		return "[^&#]*"
+	}
+	return "[^/?#&;=,]*"
+}
+
+// explodeClass returns the character class of an exploded variable in an
+// expansion of the expression, which may span several separators.
+func (p *templatePart) explodeClass() string {
+	switch p.op {
+	case '+', '#':
+		return ".*"
+	case '.':
+		return "[^/?#]*"
+	case '/', ';':
+		return "[^?#]*"
+	case '?', '&':
+		return "[^#]*"
+	}
+	return "[^/?#&;=]*"
+}
+
+// quoteLiteral quotes the literal s for a regular expression. Each byte
+// that is not valid UTF-8 is replaced by U+FFFD, which is how package regexp
+// matches such bytes.
+func quoteLiteral(s string) string {
+	if utf8.ValidString(s) {
+		return regexp.QuoteMeta(s)
+	}
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+	}
+	return regexp.QuoteMeta(b.String())
+}
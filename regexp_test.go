@@ -0,0 +1,53 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	tests := []struct {
+		raw    string
+		uri    string
+		names  []string
+		groups []string
+		values []string
+	}{
+		{"/users/{id}", "/users/42", []string{"id"}, []string{"id"}, []string{"42"}},
+		{"/users/{id}/posts/{post}", "/users/a%20b/posts/7", []string{"id", "post"}, []string{"id", "post"}, []string{"a%20b", "7"}},
+		{"/users/{id}", "/users/42/x", nil, nil, nil},
+		{"/files{/path*}", "/files/a/b/c", []string{"path"}, []string{"path"}, []string{"a/b/c"}},
+		{"/search{?q,page}", "/search?q=go&page=2", []string{"q", "page"}, []string{"q", "page"}, []string{"go", "2"}},
+		{"/search{?q,page}", "/search?page=2", []string{"q", "page"}, []string{"q", "page"}, []string{"", "2"}},
+		{"/search{?q}{&page}", "/search?q=go&page=2", []string{"q", "page"}, []string{"q", "page"}, []string{"go", "2"}},
+		{"/map{;x,y}", "/map;x=1;y", []string{"x", "y"}, []string{"x", "y"}, []string{"1", ""}},
+		{"{+base}/x{#frag}", "http://a/b/x#top", []string{"base", "frag"}, []string{"base", "frag"}, []string{"http://a/b", "top"}},
+		{"/a{.ext}", "/a.json", []string{"ext"}, []string{"ext"}, []string{"json"}},
+		{"/{user.name}/{x%41}/{id}/{id}", "/ann/1/2/3", []string{"user.name", "x%41", "id", "id"}, []string{"v1", "v2", "id", "v4"}, []string{"ann", "1", "2", "3"}},
+		{"/{a,b}", "/1,2", []string{"a", "b"}, []string{"a", "b"}, []string{"1", "2"}},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			re, names := MustParse(test.raw).Regexp()
+			m := re.FindStringSubmatch(test.uri)
+			if test.values == nil {
+				if m != nil {
+					t.Errorf("%s: want no match for %s, got %v", re, test.uri, m)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatalf("%s: want match for %s", re, test.uri)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(test.names) {
+				t.Errorf("want names %v, got %v", test.names, names)
+			}
+			if fmt.Sprint(re.SubexpNames()[1:]) != fmt.Sprint(test.groups) {
+				t.Errorf("want groups %v, got %v", test.groups, re.SubexpNames()[1:])
+			}
+			if fmt.Sprint(m[1:]) != fmt.Sprint(test.values) {
+				t.Errorf("want values %q, got %q", test.values, m[1:])
+			}
+		})
+	}
+}
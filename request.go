@@ -0,0 +1,60 @@
+package uri
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ExpandRequest expands the template and builds an HTTP request for the
+// resulting URL using the given method and context.
+func (t *Template) ExpandRequest(ctx context.Context, method string, value interface{}) (*http.Request, error) {
+	expanded, err := t.Expand(value)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, expanded, nil)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// NewRequest parses template, expands it with values and builds an HTTP
+// request with the given method, context and body for the resulting URL,
+// which must be absolute. Parsed templates are cached like those of Expand.
+func NewRequest(ctx context.Context, method, template string, values interface{}, body io.Reader) (*http.Request, error) {
+	t, err := cache.parse(template)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := t.Expand(values)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(expanded)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("expanded URL is not absolute: %s", expanded)
+	}
+	req, err := http.NewRequest(method, expanded, body)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// ExpandURL expands the template like Expand and parses the result with
+// url.Parse, so that an expansion that is not a valid URL is reported as an
+// error.
+func (t *Template) ExpandURL(value interface{}) (*url.URL, error) {
+	expanded, err := t.Expand(value)
+	if err != nil {
+		return nil, err
+	}
+	return url.Parse(expanded)
+}
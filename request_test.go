@@ -0,0 +1,109 @@
+package uri
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type requestKey struct{}
+
+func TestExpandRequest(t *testing.T) {
+	tests := []struct {
+		raw    string
+		method string
+		args   map[string]interface{}
+		out    string
+	}{
+		{"http://localhost:8080/{id}", http.MethodGet, map[string]interface{}{"id": "foo"}, "http://localhost:8080/foo"},
+		{"http://localhost:8080/items{?q,limit}", http.MethodPost, map[string]interface{}{"q": "a b", "limit": 10}, "http://localhost:8080/items?q=a%20b&limit=10"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(context.Background(), requestKey{}, "value")
+			req, err := template.ExpandRequest(ctx, test.method, test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Method != test.method {
+				t.Errorf("want method %s, got %s", test.method, req.Method)
+			}
+			if req.URL.String() != test.out {
+				t.Errorf("want %s, got %s", test.out, req.URL.String())
+			}
+			if req.Context().Value(requestKey{}) != "value" {
+				t.Errorf("request does not carry the given context")
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		raw    string
+		values interface{}
+		body   string
+		out    string
+		err    bool
+	}{
+		{"http://localhost:8080/{id}", map[string]string{"id": "foo"}, "", "http://localhost:8080/foo", false},
+		{"https://example.com/items{?q}", struct {
+			Q string `uri:"q"`
+		}{"a b"}, "{}", "https://example.com/items?q=a%20b", false},
+		{"{+base}/items", map[string]string{"base": "http://localhost"}, "", "http://localhost/items", false},
+		{"/items/{id}", map[string]string{"id": "foo"}, "", "", true},
+		{"mailto:{to}", map[string]string{"to": "a@example.com"}, "", "", true},
+		{"http://localhost/{id", nil, "", "", true},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), requestKey{}, "value")
+			req, err := NewRequest(ctx, http.MethodPut, test.raw, test.values, strings.NewReader(test.body))
+			if test.err {
+				if err == nil {
+					t.Errorf("want error, got %s", req.URL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Method != http.MethodPut {
+				t.Errorf("want method %s, got %s", http.MethodPut, req.Method)
+			}
+			if req.URL.String() != test.out {
+				t.Errorf("want %s, got %s", test.out, req.URL.String())
+			}
+			if req.Context().Value(requestKey{}) != "value" {
+				t.Errorf("request does not carry the given context")
+			}
+			if body, _ := ioutil.ReadAll(req.Body); string(body) != test.body {
+				t.Errorf("want body %q, got %q", test.body, body)
+			}
+		})
+	}
+}
+
+func TestExpandURL(t *testing.T) {
+	u, err := MustParse("https://example.com/users/{id}{?q}#{frag}").ExpandURL(map[string]string{"id": "a b", "q": "x/y", "frag": "top"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "example.com" || u.Path != "/users/a b" || u.Query().Get("q") != "x/y" || u.Fragment != "top" {
+		t.Errorf("unexpected URL %#v", u)
+	}
+	if u.String() != "https://example.com/users/a%20b?q=x%2Fy#top" {
+		t.Errorf("want lossless URL, got %s", u)
+	}
+	if _, err := MustParse("{+host}/x").ExpandURL(map[string]string{"host": "http://[::1"}); err == nil {
+		t.Error("want error for an invalid URL")
+	}
+}
@@ -0,0 +1,62 @@
+package uri
+
+import (
+	"errors"
+	"strings"
+)
+
+// Resolve resolves the URI reference ref against the absolute URI base as
+// described by RFC 3986 section 5.2, and returns the target URI.
+func Resolve(base, ref string) (string, error) {
+	b, err := ParseURI(base)
+	if err != nil {
+		return "", err
+	}
+	if !b.IsAbs() {
+		return "", errors.New("base URI is not absolute: " + base)
+	}
+	r, err := ParseURI(ref)
+	if err != nil {
+		return "", err
+	}
+	return resolve(b, r).String(), nil
+}
+
+func resolve(base, ref *URI) *URI {
+	target := *ref
+	switch {
+	case ref.Scheme != "":
+		target.Path = removeDotSegments(ref.Path)
+	case ref.HasAuthority:
+		target.Scheme = base.Scheme
+		target.Path = removeDotSegments(ref.Path)
+	default:
+		target = *base
+		target.HasFragment, target.Fragment = ref.HasFragment, ref.Fragment
+		if ref.Path == "" {
+			if ref.HasQuery {
+				target.Query, target.HasQuery = ref.Query, true
+			}
+			break
+		}
+		target.Query, target.HasQuery = ref.Query, ref.HasQuery
+		if strings.HasPrefix(ref.Path, "/") {
+			target.Path = removeDotSegments(ref.Path)
+		} else {
+			target.Path = removeDotSegments(merge(base, ref.Path))
+		}
+	}
+	return &target
+}
+
+// merge merges a relative-path reference with the path of base, as described
+// by RFC 3986 section 5.2.3.
+func merge(base *URI, path string) string {
+	if base.HasAuthority && base.Path == "" {
+		return "/" + path
+	}
+	if i := strings.LastIndexByte(base.Path, '/'); i >= 0 {
+		return base.Path[:i+1] + path
+	}
+	return path
+}
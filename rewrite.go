@@ -0,0 +1,83 @@
+package uri
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rewrite returns a new template in which every variable reference v is
+// replaced by f(v), keeping the literals and operators of t. The Name,
+// Explode and Prefix of the result replace those of the reference, so f can
+// rename variables as well as change their modifiers. An error is returned
+// if a rewritten reference is invalid, such as one with an empty name; it is
+// of type *ParseError, with offsets into the text of the rewritten
+// template. Constraints stay with the names they were set for.
+func (t *Template) Rewrite(f func(Var) Var) (*Template, error) {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.terms == nil {
+			b.WriteString(p.raw)
+			continue
+		}
+		terms := make([]templateTerm, len(p.terms))
+		for i, term := range p.terms {
+			v := f(Var{Name: term.name, Explode: term.explode, Prefix: term.truncate})
+			terms[i] = templateTerm{name: v.Name, explode: v.Explode, truncate: v.Prefix}
+		}
+		b.WriteString(p.text(terms))
+	}
+	rewritten, err := parse(b.String(), t.dotted)
+	if err != nil {
+		return nil, err
+	}
+	rewritten.truncateHandler = t.truncateHandler
+	rewritten.preProcess = t.preProcess
+	rewritten.constraints = t.constraints
+	return rewritten, nil
+}
+
+// RenameVar returns a new template in which the variable old is renamed to
+// new, keeping its operators and modifiers: renaming "user_id" to "id" turns
+// "/users/{user_id}{?user_id:3}" into "/users/{id}{?id:3}". It panics if new
+// is not a valid variable name; use Rewrite to handle such names gracefully.
+// A constraint of old applies to new in the result.
+func (t *Template) RenameVar(old, new string) *Template {
+	renamed, err := t.Rewrite(func(v Var) Var {
+		if v.Name == old {
+			v.Name = new
+		}
+		return v
+	})
+	if err != nil {
+		panic("uri: RenameVar(" + strconv.Quote(old) + ", " + strconv.Quote(new) + "): " + err.Error())
+	}
+	if c, exists := t.constraints[old]; exists {
+		renamed = renamed.Constrain(new, c)
+		delete(renamed.constraints, old)
+	}
+	return renamed
+}
+
+// text returns the expression p as it is written in a template, with terms
+// in place of its own.
+func (p *templatePart) text(terms []templateTerm) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	if p.op != 0 {
+		b.WriteByte(p.expr[0])
+	}
+	for i, term := range terms {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(term.name)
+		if term.explode {
+			b.WriteByte('*')
+		}
+		if term.truncate > 0 {
+			b.WriteString(":" + strconv.Itoa(term.truncate))
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package uri
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenameVar(t *testing.T) {
+	tests := []struct {
+		template string
+		old, new string
+		expected string
+	}{
+		{"/users/{user_id}{?user_id:3}", "user_id", "id", "/users/{id}{?id:3}"},
+		{"{+base}{/path*}{?q,p}", "path", "segments", "{+base}{/segments*}{?q,p}"},
+		{"{#a,b,a}", "a", "x", "{#x,b,x}"},
+		{"/static", "a", "b", "/static"},
+		{"{;a}{.b}{&c}", "d", "e", "{;a}{.b}{&c}"},
+	}
+	for _, test := range tests {
+		renamed := MustParse(test.template).RenameVar(test.old, test.new)
+		if renamed.String() != test.expected {
+			t.Errorf("%s: RenameVar(%q, %q) = %q, expected %q", test.template, test.old, test.new, renamed.String(), test.expected)
+		}
+	}
+}
+
+func TestRenameVarDotted(t *testing.T) {
+	template, err := ParseDotted("/users/{.User.ID}{..Ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := template.RenameVar(".User.ID", ".User.Name")
+	if expected := "/users/{.User.Name}{..Ext}"; renamed.String() != expected {
+		t.Errorf("expected %q, got %q", expected, renamed.String())
+	}
+	actual, err := renamed.Expand(map[string]interface{}{"User": map[string]interface{}{"Name": "ann"}, "Ext": "json"})
+	if expected := "/users/ann.json"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+}
+
+func TestRenameVarInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("RenameVar did not panic on an invalid name")
+		}
+	}()
+	MustParse("{a}").RenameVar("a", "a b")
+}
+
+func TestRewrite(t *testing.T) {
+	template := MustParse("/search{?q,tags*}{&page}")
+	rewritten, err := template.Rewrite(func(v Var) Var {
+		v.Name = strings.ToUpper(v.Name)
+		v.Explode = false
+		if v.Name == "Q" {
+			v.Prefix = 10
+		}
+		return v
+	})
+	if expected := "/search{?Q:10,TAGS}{&PAGE}"; err != nil || rewritten.String() != expected {
+		t.Errorf("expected %q, got %v, %v", expected, rewritten, err)
+	}
+	_, err = template.Rewrite(func(v Var) Var {
+		v.Name = ""
+		return v
+	})
+	if perr, ok := err.(*ParseError); !ok || perr.Reason != ReasonEmpty {
+		t.Errorf("expected empty name error, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package uri
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// A URI is a URI reference as defined by RFC 3986, split into its
+// components. Components hold their text as it appears in the reference,
+// still percent-encoded, so that String reproduces the parsed reference
+// exactly. The Has fields record components that are present but empty,
+// such as the query of "http://example.com/?".
+type URI struct {
+	Scheme       string
+	HasAuthority bool
+	UserInfo     string
+	HasUserInfo  bool
+	Host         string
+	Port         string
+	HasPort      bool
+	Path         string
+	Query        string
+	HasQuery     bool
+	Fragment     string
+	HasFragment  bool
+}
+
+// ParseURI parses s as a URI reference, either a URI or a relative reference.
+// It is stricter than url.Parse: every character must be allowed by the
+// grammar of RFC 3986 for its component, every "%" must start a
+// percent-encoded triplet, a port must consist of digits, and an IP literal
+// must hold a valid IPv6 address or IPvFuture.
+func ParseURI(s string) (*URI, error) {
+	u := new(URI)
+	rest := s
+	if i := strings.IndexAny(rest, ":/?#"); i >= 0 && rest[i] == ':' {
+		u.Scheme = rest[:i]
+		if !isScheme(u.Scheme) {
+			return nil, fmt.Errorf("invalid scheme %q", u.Scheme)
+		}
+		rest = rest[i+1:]
+	}
+	if i := strings.IndexByte(rest, '#'); i >= 0 {
+		u.Fragment, u.HasFragment = rest[i+1:], true
+		rest = rest[:i]
+		if err := checkChars("fragment", u.Fragment, "/?"); err != nil {
+			return nil, err
+		}
+	}
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		u.Query, u.HasQuery = rest[i+1:], true
+		rest = rest[:i]
+		if err := checkChars("query", u.Query, "/?"); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(rest, "//") {
+		u.HasAuthority = true
+		rest = rest[2:]
+		authority := rest
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			authority, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		if err := u.parseAuthority(authority); err != nil {
+			return nil, err
+		}
+	}
+	u.Path = rest
+	if err := checkChars("path", u.Path, "/"); err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" && !u.HasAuthority {
+		if i := strings.IndexAny(u.Path, ":/"); i >= 0 && u.Path[i] == ':' {
+			return nil, fmt.Errorf("first path segment %q of a relative reference contains a colon", u.Path[:strings.IndexByte(u.Path+"/", '/')])
+		}
+	}
+	return u, nil
+}
+
+func (u *URI) parseAuthority(authority string) error {
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		u.UserInfo, u.HasUserInfo = authority[:i], true
+		authority = authority[i+1:]
+		if err := checkChars("userinfo", u.UserInfo, ":"); err != nil {
+			return err
+		}
+	}
+	host := authority
+	if i := strings.LastIndexByte(authority, ':'); i >= 0 && !strings.Contains(authority[i:], "]") {
+		host, u.Port, u.HasPort = authority[:i], authority[i+1:], true
+		for i := 0; i < len(u.Port); i++ {
+			if u.Port[i] < '0' || u.Port[i] > '9' {
+				return fmt.Errorf("invalid port %q", u.Port)
+			}
+		}
+	}
+	u.Host = host
+	if strings.HasPrefix(host, "[") {
+		if !strings.HasSuffix(host, "]") || !isIPLiteral(host[1:len(host)-1]) {
+			return fmt.Errorf("invalid IP literal %q", host)
+		}
+		return nil
+	}
+	return checkChars("host", host, "")
+}
+
+// checkChars reports an error if s contains a character that is neither
+// unreserved, a sub-delim, a percent-encoded triplet, nor one of extra,
+// which for path, query and fragment components also includes ":" and "@".
+func checkChars(component, s, extra string) error {
+	if component == "path" || component == "query" || component == "fragment" {
+		extra += ":@"
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if !isTriplet(s, i) {
+				return fmt.Errorf("invalid percent-encoding in %s %q", component, s)
+			}
+			i += 2
+		case isUnreserved(c) || strings.IndexByte("!$&'()*+,;=", c) >= 0 || strings.IndexByte(extra, c) >= 0:
+		default:
+			return fmt.Errorf("invalid character %q in %s %q", c, component, s)
+		}
+	}
+	return nil
+}
+
+func isScheme(s string) bool {
+	if s == "" || !('A' <= s[0] && s[0] <= 'Z' || 'a' <= s[0] && s[0] <= 'z') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func isIPLiteral(s string) bool {
+	if strings.HasPrefix(s, "v") || strings.HasPrefix(s, "V") {
+		dot := strings.IndexByte(s, '.')
+		if dot < 2 || dot == len(s)-1 {
+			return false
+		}
+		for i := 1; i < dot; i++ {
+			if !ishex(s[i]) {
+				return false
+			}
+		}
+		for i := dot + 1; i < len(s); i++ {
+			if !isUnreserved(s[i]) && strings.IndexByte("!$&'()*+,;=:", s[i]) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return strings.Contains(s, ":") && net.ParseIP(s) != nil
+}
+
+// IsAbs reports whether u is an absolute URI, that is, has a scheme.
+func (u *URI) IsAbs() bool {
+	return u.Scheme != ""
+}
+
+// Authority returns the authority component: the userinfo, host and port.
+func (u *URI) Authority() string {
+	var b strings.Builder
+	if u.HasUserInfo {
+		b.WriteString(u.UserInfo)
+		b.WriteByte('@')
+	}
+	b.WriteString(u.Host)
+	if u.HasPort {
+		b.WriteByte(':')
+		b.WriteString(u.Port)
+	}
+	return b.String()
+}
+
+// Segments returns the segments of the path, without the leading "/" of an
+// absolute path. The segments are still percent-encoded.
+func (u *URI) Segments() []string {
+	if u.Path == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+}
+
+// String reassembles the components into a URI reference. For a URI
+// returned by ParseURI it is the parsed text.
+func (u *URI) String() string {
+	var b strings.Builder
+	if u.Scheme != "" {
+		b.WriteString(u.Scheme)
+		b.WriteByte(':')
+	}
+	if u.HasAuthority {
+		b.WriteString("//")
+		b.WriteString(u.Authority())
+	}
+	b.WriteString(u.Path)
+	if u.HasQuery {
+		b.WriteByte('?')
+		b.WriteString(u.Query)
+	}
+	if u.HasFragment {
+		b.WriteByte('#')
+		b.WriteString(u.Fragment)
+	}
+	return b.String()
+}
+
+// ExpandURI expands the template like Expand and parses the result with
+// ParseURI.
+func (t *Template) ExpandURI(value interface{}) (*URI, error) {
+	expanded, err := t.Expand(value)
+	if err != nil {
+		return nil, err
+	}
+	return ParseURI(expanded)
+}
+
+// MatchURI matches u against the template like Match.
+func (t *Template) MatchURI(u *URI) (map[string]string, bool) {
+	return t.Match(u.String())
+}
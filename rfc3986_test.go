@@ -0,0 +1,100 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want URI
+	}{
+		{"http://example.com", URI{Scheme: "http", HasAuthority: true, Host: "example.com"}},
+		{"https://user:pw@example.com:8080/a/b%20c?q=1&r=/x?#frag",
+			URI{Scheme: "https", HasAuthority: true, UserInfo: "user:pw", HasUserInfo: true, Host: "example.com", Port: "8080", HasPort: true, Path: "/a/b%20c", Query: "q=1&r=/x?", HasQuery: true, Fragment: "frag", HasFragment: true}},
+		{"http://[::1]:80/", URI{Scheme: "http", HasAuthority: true, Host: "[::1]", Port: "80", HasPort: true, Path: "/"}},
+		{"http://[v7.a:b]/", URI{Scheme: "http", HasAuthority: true, Host: "[v7.a:b]", Path: "/"}},
+		{"file:///etc/hosts", URI{Scheme: "file", HasAuthority: true, Path: "/etc/hosts"}},
+		{"mailto:a@example.com", URI{Scheme: "mailto", Path: "a@example.com"}},
+		{"urn:isbn:0451450523", URI{Scheme: "urn", Path: "isbn:0451450523"}},
+		{"http://example.com:?#", URI{Scheme: "http", HasAuthority: true, Host: "example.com", HasPort: true, HasQuery: true, HasFragment: true}},
+		{"//example.com/x", URI{HasAuthority: true, Host: "example.com", Path: "/x"}},
+		{"../a/b;p=1", URI{Path: "../a/b;p=1"}},
+		{"./a:b", URI{Path: "./a:b"}},
+		{"?q", URI{Query: "q", HasQuery: true}},
+		{"", URI{}},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			u, err := ParseURI(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *u != test.want {
+				t.Errorf("want %+v, got %+v", test.want, *u)
+			}
+			if u.String() != test.raw {
+				t.Errorf("want %s, got %s", test.raw, u.String())
+			}
+		})
+	}
+}
+
+func TestParseURIErrors(t *testing.T) {
+	for _, raw := range []string{
+		"1http://example.com",
+		"ht_tp://example.com",
+		"http://exa mple.com/",
+		"http://example.com:80a/",
+		"http://[::1/",
+		"http://[1.2.3.4]/",
+		"http://[vz.a]/",
+		"http://example.com/100%",
+		"http://example.com/%zz",
+		"http://example.com/a b",
+		"http://example.com/?q=<x>",
+		"http://example.com/#a#b",
+		"http://a b@example.com/",
+		":x",
+		"http://example.com/[x]",
+	} {
+		if u, err := ParseURI(raw); err == nil {
+			t.Errorf("%s: want error, got %+v", raw, *u)
+		}
+	}
+}
+
+func TestURIAccessors(t *testing.T) {
+	u, err := ParseURI("http://user@example.com:8080/a/b%2Fc/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.IsAbs() {
+		t.Error("want absolute URI")
+	}
+	if u.Authority() != "user@example.com:8080" {
+		t.Errorf("unexpected authority %s", u.Authority())
+	}
+	if segments := fmt.Sprintf("%q", u.Segments()); segments != `["a" "b%2Fc" ""]` {
+		t.Errorf("unexpected segments %s", segments)
+	}
+}
+
+func TestExpandMatchURI(t *testing.T) {
+	template := MustParse("https://example.com/users/{id}{?q}")
+	u, err := template.ExpandURI(map[string]string{"id": "a b", "q": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/users/a%20b" || u.Query != "q=x" {
+		t.Errorf("unexpected URI %+v", *u)
+	}
+	values, ok := template.MatchURI(u)
+	if !ok || values["id"] != "a b" || values["q"] != "x" {
+		t.Errorf("unexpected match %v %v", values, ok)
+	}
+	if _, err := MustParse("http://example.com:8o/{x}").ExpandURI(map[string]string{"x": "a"}); err == nil {
+		t.Error("want error for an invalid URI")
+	}
+}
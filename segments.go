@@ -0,0 +1,178 @@
+package uri
+
+import "strings"
+
+// A Segment is one piece of a parsed template: either literal text or, if
+// Expression is not nil, an expression.
+type Segment struct {
+	Literal    string      `json:"literal,omitempty"`
+	Expression *Expression `json:"expression,omitempty"`
+}
+
+// An Expression describes a parsed template expression.
+type Expression struct {
+	// Operator is one of "+", "#", ".", "/", ";", "?" or "&", or empty for
+	// simple string expansion.
+	Operator string `json:"operator,omitempty"`
+	Vars     []Var  `json:"vars"`
+}
+
+// A Var describes a variable reference within an expression.
+type Var struct {
+	Name string `json:"name"`
+	// Explode is set by the "*" modifier.
+	Explode bool `json:"explode,omitempty"`
+	// Prefix is the maximum length set by the ":" modifier, or 0.
+	Prefix int `json:"prefix,omitempty"`
+}
+
+// Segments returns the literals and expressions of the template in order.
+func (t *Template) Segments() []Segment {
+	var segments []Segment
+	for _, p := range t.parts {
+		if p.terms == nil {
+			if len(p.raw) > 0 {
+				segments = append(segments, Segment{Literal: p.raw})
+			}
+			continue
+		}
+		segments = append(segments, Segment{Expression: p.expression()})
+	}
+	return segments
+}
+
+// Names returns the names of the variables referenced by the template in
+// order of first appearance, without duplicates.
+func (t *Template) Names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range t.parts {
+		for _, term := range p.terms {
+			if !seen[term.name] {
+				seen[term.name] = true
+				names = append(names, term.name)
+			}
+		}
+	}
+	return names
+}
+
+// Expressions returns the expressions of the template in order.
+func (t *Template) Expressions() []Expression {
+	var expressions []Expression
+	for _, p := range t.parts {
+		if p.terms != nil {
+			expressions = append(expressions, *p.expression())
+		}
+	}
+	return expressions
+}
+
+// Select returns a new template that keeps the literals of t but references
+// only the named variables: the other variables are removed from each
+// expression, and expressions left without variables are removed entirely.
+// Selecting "page" and "limit" from "/items{?q,page,limit}" yields
+// "/items{?page,limit}", and selecting them from "{?q,page,limit}" yields
+// just the query expression "{?page,limit}".
+func (t *Template) Select(names ...string) *Template {
+	selected := &Template{
+		dotted:          t.dotted,
+		truncateHandler: t.truncateHandler,
+		preProcess:      t.preProcess,
+		constraints:     t.constraints,
+	}
+	var raw strings.Builder
+	for _, p := range t.parts {
+		if p.terms == nil {
+			raw.WriteString(p.raw)
+			if last := len(selected.parts) - 1; last >= 0 && selected.parts[last].terms == nil {
+				selected.parts[last].raw += p.raw
+			} else {
+				selected.parts = append(selected.parts, p)
+			}
+			continue
+		}
+		var terms []templateTerm
+		for _, term := range p.terms {
+			if contains(names, term.name) {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) == 0 {
+			continue
+		}
+		text := p.text(terms)
+		p.terms = terms
+		p.expr = text[1 : len(text)-1]
+		raw.WriteString(text)
+		selected.parts = append(selected.parts, p)
+	}
+	selected.raw = raw.String()
+	selected.static = len(selected.parts) == 1
+	return selected
+}
+
+func (p *templatePart) expression() *Expression {
+	expression := &Expression{Vars: make([]Var, len(p.terms))}
+	if p.op != 0 {
+		expression.Operator = string(p.op)
+	}
+	for i, term := range p.terms {
+		expression.Vars[i] = Var{Name: term.name, Explode: term.explode, Prefix: term.truncate}
+	}
+	return expression
+}
+
+// Canonical returns the template text in a normal form: prefix modifiers
+// are written without leading zeros, a zero prefix is dropped, and
+// percent-encodings in literals use uppercase hexadecimal digits. Templates
+// with the same canonical form expand to equivalent URIs, which may differ
+// in the case of percent-encodings in literals, as literals are expanded
+// as written.
+func (t *Template) Canonical() string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.terms == nil {
+			b.WriteString(upperTriplets(p.raw))
+			continue
+		}
+		b.WriteByte('{')
+		if p.op != 0 {
+			b.WriteByte(p.op)
+		}
+		b.WriteString(joinTerms(p.terms))
+		b.WriteByte('}')
+	}
+	return b.String()
+}
+
+// Equal reports whether t and other have the same canonical form and are
+// parsed the same way, so that they expand to equivalent URIs for all
+// values, as described for Canonical.
+func (t *Template) Equal(other *Template) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	return t.dotted == other.dotted && t.Canonical() == other.Canonical()
+}
+
+func upperTriplets(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	b := []byte(s)
+	for i := range b {
+		if isTriplet(s, i) {
+			b[i+1] = upperHex(b[i+1])
+			b[i+2] = upperHex(b[i+2])
+		}
+	}
+	return string(b)
+}
+
+func upperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,127 @@
+package uri
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	template, err := Parse("http://localhost:8080/{id}/items{?q,limit:3}{#frag*}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Segment{
+		{Literal: "http://localhost:8080/"},
+		{Expression: &Expression{Vars: []Var{{Name: "id"}}}},
+		{Literal: "/items"},
+		{Expression: &Expression{Operator: "?", Vars: []Var{{Name: "q"}, {Name: "limit", Prefix: 3}}}},
+		{Expression: &Expression{Operator: "#", Vars: []Var{{Name: "frag", Explode: true}}}},
+	}
+	got := template.Segments()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		raw   string
+		names []string
+	}{
+		{"http://localhost:8080/items", nil},
+		{"http://localhost:8080/{id}", []string{"id"}},
+		{"{/user,repo}{?q,user,limit:3}{&page}{#repo}", []string{"user", "repo", "q", "limit", "page"}},
+	}
+	for _, test := range tests {
+		template, err := Parse(test.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := template.Names(); !reflect.DeepEqual(test.names, names) {
+			t.Errorf("%s: want %v, got %v", test.raw, test.names, names)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		raw       string
+		canonical string
+	}{
+		{"/users{/id}{?q,page}", "/users{/id}{?q,page}"},
+		{"/users/{id:03}", "/users/{id:3}"},
+		{"/users/{id:0}", "/users/{id}"},
+		{"/a%2fb{+p}%7e", "/a%2Fb{+p}%7E"},
+		{"/100%", "/100%"},
+	}
+	for _, test := range tests {
+		if canonical := MustParse(test.raw).Canonical(); canonical != test.canonical {
+			t.Errorf("%s: want %s, got %s", test.raw, test.canonical, canonical)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	dotted, err := ParseDotted("/{.a}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		a, b  *Template
+		equal bool
+	}{
+		{MustParse("/{id:02}"), MustParse("/{id:2}"), true},
+		{MustParse("/%2f{id}"), MustParse("/%2F{id}"), true},
+		{MustParse("/{a,b}"), MustParse("/{b,a}"), false},
+		{MustParse("/{a}"), MustParse("/{+a}"), false},
+		{MustParse("/{.a}"), dotted, false},
+		{MustParse("/"), nil, false},
+		{nil, nil, true},
+	}
+	for i, test := range tests {
+		if equal := test.a.Equal(test.b); equal != test.equal {
+			t.Errorf("%d: want %v, got %v", i, test.equal, equal)
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	expressions := MustParse("/items{/id}{?q,page:3}").Expressions()
+	expected := []Expression{
+		{Operator: "/", Vars: []Var{{Name: "id"}}},
+		{Operator: "?", Vars: []Var{{Name: "q"}, {Name: "page", Prefix: 3}}},
+	}
+	if !reflect.DeepEqual(expressions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, expressions)
+	}
+	if expressions := MustParse("/static").Expressions(); expressions != nil {
+		t.Errorf("expected no expressions, got %+v", expressions)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		template string
+		names    []string
+		expected string
+	}{
+		{"/items{?q,page,limit}", []string{"page", "limit"}, "/items{?page,limit}"},
+		{"{?q,page,limit}", []string{"limit", "page"}, "{?page,limit}"},
+		{"/items{/id}/sub{?q}", []string{"q"}, "/items/sub{?q}"},
+		{"/items{/id*}{?q:3}{&page}", []string{"id", "q"}, "/items{/id*}{?q:3}"},
+		{"/items{?q}", nil, "/items"},
+	}
+	for _, test := range tests {
+		selected := MustParse(test.template).Select(test.names...)
+		if selected.String() != test.expected {
+			t.Errorf("%s: Select(%q) = %q, expected %q", test.template, test.names, selected.String(), test.expected)
+		}
+		if !selected.Equal(MustParse(test.expected)) {
+			t.Errorf("%s: Select(%q) parsed differently from %q: %#v", test.template, test.names, test.expected, selected)
+		}
+	}
+	actual, err := MustParse("/items{?q,page,limit}").Select("page", "limit").Expand(map[string]interface{}{"q": "x", "page": 2, "limit": 10})
+	if expected := "/items?page=2&limit=10"; err != nil || actual != expected {
+		t.Errorf("expected %q, got %q, %v", expected, actual, err)
+	}
+}
@@ -0,0 +1,91 @@
+package uri
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// streamFlushSize is the number of buffered bytes after which the elements
+// of a stream are written to the io.Writer given to ExpandTo.
+const streamFlushSize = 32 << 10
+
+// A stream is a list value whose elements are produced one at a time, from
+// a channel or an iterator function, rather than held in memory.
+type stream func(yield func(interface{}) bool)
+
+var boolType = reflect.TypeOf(false)
+
+// toStream returns the stream of v if it is a channel that can be received
+// from, or an iterator function such as iter.Seq: a function taking a yield
+// function that receives each element and returns false to stop.
+func toStream(v reflect.Value) (stream, bool) {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0:
+		return func(yield func(interface{}) bool) {
+			for {
+				e, ok := v.Recv()
+				if !ok || !yield(e.Interface()) {
+					return
+				}
+			}
+		}, true
+	case t.Kind() == reflect.Func && t.NumIn() == 1 && t.NumOut() == 0:
+		y := t.In(0)
+		if y.Kind() != reflect.Func || y.NumIn() != 1 || y.NumOut() != 1 || y.Out(0) != boolType {
+			return nil, false
+		}
+		if seq, ok := v.Interface().(func(func(interface{}) bool)); ok {
+			return seq, true
+		}
+		return func(yield func(interface{}) bool) {
+			v.Call([]reflect.Value{reflect.MakeFunc(y, func(args []reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(yield(args[0].Interface()))}
+			})})
+		}, true
+	}
+	return nil, false
+}
+
+// expandStream expands the elements of s like those of a list and reports
+// whether there were any. Elements are written to the writer given to
+// ExpandTo as buf fills up, so that long streams are not held in memory.
+func (t *templatePart) expandStream(buf *bytes.Buffer, term templateTerm, s stream, e *expander) (bool, error) {
+	var err error
+	n := 0
+	s(func(value interface{}) bool {
+		if n == 0 && !term.explode {
+			t.expandName(buf, term.name, false)
+		} else if term.explode && n > 0 {
+			e.writeSep(buf, t)
+		} else if n > 0 {
+			buf.WriteString(",")
+		}
+		n++
+		var v string
+		if v, err = e.format(term.name, value); err != nil {
+			return false
+		}
+		v = e.truncate(t, term, v)
+		if t.named && term.explode {
+			t.expandName(buf, term.name, len(v) == 0)
+		}
+		buf.WriteString(e.escapeValue(t, term.name, "", v))
+		err = e.flush(buf)
+		return err == nil
+	})
+	return n > 0, err
+}
+
+// flush writes buf to the writer given to ExpandTo once it holds
+// streamFlushSize bytes, if buf is the buffer of the expression being
+// expanded there.
+func (e *expander) flush(buf *bytes.Buffer) error {
+	if buf != e.partBuf || buf.Len() < streamFlushSize {
+		return nil
+	}
+	e.flushed = true
+	_, err := e.w.Write(buf.Bytes())
+	buf.Reset()
+	return err
+}
@@ -0,0 +1,104 @@
+package uri
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func ints(n int) func(yield func(int) bool) {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestExpandStream(t *testing.T) {
+	strs := func(yield func(interface{}) bool) {
+		_ = yield("a b") && yield("c")
+	}
+	tests := []struct {
+		raw   string
+		value func() interface{}
+		out   string
+	}{
+		{"{/ids}", func() interface{} { return ints(3) }, "/0,1,2"},
+		{"{/ids*}", func() interface{} { return ints(3) }, "/0/1/2"},
+		{"{?ids}", func() interface{} { return ints(3) }, "?ids=0,1,2"},
+		{"{?ids*}", func() interface{} { return ints(3) }, "?ids=0&ids=1&ids=2"},
+		{"{?x,ids,y}", func() interface{} { return ints(0) }, "?x=1&y=2"},
+		{"{?ids}", func() interface{} { return ints(0) }, ""},
+		{"{ids:1}", func() interface{} { return strs }, "a,c"},
+		{"{;ids*}", func() interface{} {
+			c := make(chan string, 2)
+			c <- "a"
+			c <- "b"
+			close(c)
+			return c
+		}, ";ids=a;ids=b"},
+		{"{ids}", func() interface{} {
+			c := make(chan int)
+			close(c)
+			return (<-chan int)(c)
+		}, ""},
+	}
+	for _, test := range tests {
+		values := map[string]interface{}{"ids": test.value(), "x": 1, "y": 2}
+		out, err := MustParse(test.raw).Expand(values)
+		if err != nil || out != test.out {
+			t.Errorf("%s: want %s, got %s, %v", test.raw, test.out, out, err)
+		}
+		var b strings.Builder
+		values["ids"] = test.value()
+		if err := MustParse(test.raw).ExpandTo(&b, values); err != nil || b.String() != test.out {
+			t.Errorf("%s: ExpandTo: want %s, got %s, %v", test.raw, test.out, b.String(), err)
+		}
+	}
+}
+
+type recordingWriter struct {
+	writes, max int
+	b           strings.Builder
+	err         error
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.b.Write(p)
+}
+
+func TestExpandToStream(t *testing.T) {
+	const n = 100000
+	var w recordingWriter
+	if err := MustParse("/batch{?id*}{&sorted}").ExpandTo(&w, map[string]interface{}{"id": ints(n), "sorted": true}); err != nil {
+		t.Fatal(err)
+	}
+	out := w.b.String()
+	if !strings.HasPrefix(out, "/batch?id=0&id=1&") || !strings.HasSuffix(out, "&id="+strconv.Itoa(n-1)+"&sorted=true") {
+		t.Errorf("unexpected expansion %.40s...%s", out, out[len(out)-40:])
+	}
+	if w.writes < 10 || w.max > 2*streamFlushSize {
+		t.Errorf("expansion of %d bytes not streamed: %d writes of at most %d bytes", len(out), w.writes, w.max)
+	}
+
+	consumed := 0
+	failing := recordingWriter{err: errors.New("closed")}
+	err := MustParse("{/id*}").ExpandTo(&failing, map[string]interface{}{"id": func(yield func(int) bool) {
+		for i := 0; i < n && yield(i); i++ {
+			consumed++
+		}
+	}})
+	if err == nil || err.Error() != "closed" || consumed == n {
+		t.Errorf("want the stream to stop at the write error, got %v after %d elements", err, consumed)
+	}
+}
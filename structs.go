@@ -0,0 +1,235 @@
+package uri
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A structField describes how a struct field is expanded.
+type structField struct {
+	index     int
+	name      string
+	omitEmpty bool
+	layout    string
+	number    NumberFormat
+	bools     BoolFormat
+}
+
+type fieldsKey struct {
+	t        reflect.Type
+	jsonTags bool
+}
+
+// fieldCache maps a fieldsKey to the []structField of its type.
+var fieldCache sync.Map
+
+func struct2map(v interface{}, opts *ExpandOpts) (map[string]interface{}, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Type().Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil, false
+		}
+		return struct2map(value.Elem().Interface(), opts)
+	case reflect.Struct:
+		return fieldsMap(value, cachedFields(value.Type(), opts.JSONTags)), true
+	}
+	return nil, false
+}
+
+// struct2pairs returns the fields of a struct, or of a non-nil pointer to
+// one, as pairs in declaration order.
+func struct2pairs(v interface{}, opts *ExpandOpts) (Ordered, bool) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, false
+	}
+	fields := cachedFields(value.Type(), opts.JSONTags)
+	pairs := make(Ordered, 0, len(fields))
+	for _, f := range fields {
+		if v, ok := fieldValue(value, f); ok {
+			pairs = append(pairs, Pair{f.name, v})
+		}
+	}
+	return pairs, true
+}
+
+// nestedFields adds the fields of structs nested within fields to dst,
+// named by their path joined with opts.NameSeparator, and returns dst. It
+// allocates dst only if there are nested structs. Cycles through pointers
+// stop at defaultMaxDepth.
+func nestedFields(dst, fields map[string]interface{}, prefix string, opts *ExpandOpts, depth int) map[string]interface{} {
+	if depth >= defaultMaxDepth {
+		return dst
+	}
+	for name, v := range fields {
+		if isScalar(v) || !isStruct(v) {
+			continue
+		}
+		if dst == nil {
+			dst = make(map[string]interface{})
+		}
+		nested, _ := struct2map(v, opts)
+		path := prefix + name + opts.nameSeparator()
+		for k, sub := range nested {
+			dst[path+k] = sub
+		}
+		dst = nestedFields(dst, nested, path, opts, depth+1)
+	}
+	return dst
+}
+
+func fieldsMap(value reflect.Value, fields []structField) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := fieldValue(value, f); ok {
+			m[f.name] = v
+		}
+	}
+	return m
+}
+
+// fieldValue returns the value of the field f of the struct value, unless it
+// is omitted because it is empty.
+func fieldValue(value reflect.Value, f structField) (interface{}, bool) {
+	field := value.Field(f.index)
+	if f.omitEmpty && isEmptyValue(field) {
+		return nil, false
+	}
+	v := field.Interface()
+	if f.layout != "" {
+		v = formatTime(v, f.layout)
+	}
+	if f.number != (NumberFormat{}) {
+		if s, ok, _ := f.number.format(v); ok {
+			v = s
+		}
+	}
+	if s, ok := f.bools.format(v); ok {
+		v = s
+	}
+	return v, true
+}
+
+func cachedFields(t reflect.Type, jsonTags bool) []structField {
+	key := fieldsKey{t, jsonTags}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.([]structField)
+	}
+	fields, _ := fieldCache.LoadOrStore(key, typeFields(t, jsonTags))
+	return fields.([]structField)
+}
+
+func typeFields(t reflect.Type, jsonTags bool) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		f := structField{index: i}
+		tag := t.Field(i).Tag
+		if strings.Contains(string(tag), ":") {
+			uriTag := tag.Get("uri")
+			if uriTag == "-" {
+				continue
+			}
+			f = parseTag(uriTag)
+			f.index = i
+		} else {
+			f.name = strings.TrimSpace(string(tag))
+		}
+		if len(f.name) == 0 && jsonTags {
+			if jsonTag, ok := tag.Lookup("json"); ok {
+				if jsonTag == "-" {
+					continue
+				}
+				options := strings.Split(jsonTag, ",")
+				f.name = options[0]
+				f.omitEmpty = f.omitEmpty || contains(options[1:], "omitempty")
+			}
+		}
+		if len(f.name) == 0 {
+			f.name = t.Field(i).Name
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// parseTag splits a uri tag into the variable name and its options. The
+// omitempty option treats zero values as undefined, the float and base
+// options set the NumberFormat of the field, as in "float=f2" and
+// "base=16", and the bool option its BoolFormat, as in "bool=1/0". The
+// layout option sets the time layout and extends to the end of the tag, so
+// it may contain commas:
+//
+//	`uri:"since,omitempty,layout=Mon, 02 Jan 2006"`
+func parseTag(tag string) (f structField) {
+	options := strings.Split(tag, ",")
+	f.name = options[0]
+	for i, option := range options[1:] {
+		switch {
+		case option == "omitempty":
+			f.omitEmpty = true
+		case strings.HasPrefix(option, "layout="):
+			f.layout = strings.Join(options[i+1:], ",")[len("layout="):]
+			return f
+		default:
+			parseNumberOption(&f.number, option)
+			parseBoolOption(&f.bools, option)
+		}
+	}
+	return f
+}
+
+// formatTime formats v with layout if it is a time.Time or a non-nil
+// *time.Time, and returns it unchanged otherwise.
+func formatTime(v interface{}, layout string) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout)
+	case *time.Time:
+		if t != nil {
+			return t.Format(layout)
+		}
+	}
+	return v
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether v is empty in the sense of the omitempty
+// option of encoding/json. The zero time.Time is empty as well.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if t, isTime := v.Interface().(time.Time); isTime {
+			return t.IsZero()
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type benchmarkQuery struct {
+	Date   string `uri:"date"`
+	Name   string `uri:"name"`
+	Limit  int    `json:"limit,omitempty"`
+	Offset int    `json:"offset"`
+	Sort   string
+}
+
+func TestStructFieldsConcurrent(t *testing.T) {
+	template, err := Parse("/{?date,name,limit,offset,Sort}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/?date=2017-07-13&name=foo&offset=5&Sort=asc"
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				out, err := template.ExpandWithOpts(benchmarkQuery{Date: "2017-07-13", Name: "foo", Offset: 5, Sort: "asc"}, ExpandOpts{JSONTags: true})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if out != want {
+					t.Errorf("want %s, got %s", want, out)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkStruct2Map(b *testing.B) {
+	value := benchmarkQuery{Date: "2017-07-13", Name: "foo", Limit: 10, Sort: "asc"}
+	b.Run("cached", func(b *testing.B) {
+		opts := &ExpandOpts{JSONTags: true}
+		for i := 0; i < b.N; i++ {
+			struct2map(value, opts)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		v := reflect.ValueOf(value)
+		for i := 0; i < b.N; i++ {
+			fieldsMap(v, typeFields(v.Type(), true))
+		}
+	})
+}
+
+func BenchmarkExpandStruct(b *testing.B) {
+	template, err := Parse("/{?date,name,limit,offset,Sort}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	value := benchmarkQuery{Date: "2017-07-13", Name: "foo", Limit: 10, Sort: "asc"}
+	for i := 0; i < b.N; i++ {
+		template.Expand(value)
+	}
+}
+
+func TestStructTagOptions(t *testing.T) {
+	type query struct {
+		ID     string    `uri:"id"`
+		Secret string    `uri:"-"`
+		Page   int       `uri:"page,omitempty"`
+		Q      string    `uri:"q,omitempty"`
+		Tags   []string  `uri:"tags,omitempty"`
+		Since  time.Time `uri:"since,omitempty,layout=2006-01-02"`
+		Limit  int       `uri:",omitempty" json:"limit"`
+	}
+	since := time.Date(2017, 7, 13, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value query
+		out   string
+	}{
+		{query{ID: "a", Secret: "s"}, "/a"},
+		{query{ID: "a", Page: 2, Q: "x"}, "/a?page=2&q=x"},
+		{query{ID: "a", Tags: []string{"b", "c"}, Since: since, Limit: 5}, "/a?tags=b,c&since=2017-07-13&limit=5"},
+	}
+	template := MustParse("/{id}{?Secret,page,q,tags,since,limit}")
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := template.ExpandWithOpts(test.value, ExpandOpts{JSONTags: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag       string
+		name      string
+		omitEmpty bool
+		layout    string
+	}{
+		{"id", "id", false, ""},
+		{"id,omitempty", "id", true, ""},
+		{",omitempty", "", true, ""},
+		{"at,layout=Mon, 02 Jan 2006", "at", false, "Mon, 02 Jan 2006"},
+		{"at,omitempty,layout=2006", "at", true, "2006"},
+		{"at,unknown", "at", false, ""},
+	}
+	for _, test := range tests {
+		f := parseTag(test.tag)
+		name, omitEmpty, layout := f.name, f.omitEmpty, f.layout
+		if name != test.name || omitEmpty != test.omitEmpty || layout != test.layout {
+			t.Errorf("%q: want %q %v %q, got %q %v %q", test.tag, test.name, test.omitEmpty, test.layout, name, omitEmpty, layout)
+		}
+	}
+}
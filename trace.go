@@ -0,0 +1,49 @@
+package uri
+
+// A Substitution records the expansion of a single value, such as a string
+// variable or one element of a list or associative array.
+type Substitution struct {
+	// Name is the name of the variable.
+	Name string
+	// Key is the key of the value within an associative array, if any.
+	Key string
+	// Raw is the value before escaping, after any prefix truncation.
+	Raw string
+	// Escaped is the value as it appears in the expansion.
+	Escaped string
+	// Operator is the operator of the expression, or empty for simple
+	// string expansion.
+	Operator string
+	// Reserved reports whether reserved characters were left unencoded.
+	Reserved bool
+}
+
+// ExpandTrace expands a URI template like Expand and also returns a record
+// of every value substituted into the result, in order.
+func (t *Template) ExpandTrace(value interface{}) (string, []Substitution, error) {
+	trace := []Substitution{}
+	e := &expander{opts: &ExpandOpts{}, trace: &trace}
+	expanded, err := e.expand(t, value)
+	if err != nil {
+		return "", nil, err
+	}
+	return expanded, trace, nil
+}
+
+func (e *expander) escapeValue(p *templatePart, name, key, s string) string {
+	escaped := e.escape(p, s)
+	if e.trace != nil {
+		substitution := Substitution{
+			Name:     name,
+			Key:      key,
+			Raw:      s,
+			Escaped:  escaped,
+			Reserved: p.allowReserved && e.opts.Escaping != OAuth1,
+		}
+		if p.op != 0 {
+			substitution.Operator = string(p.op)
+		}
+		*e.trace = append(*e.trace, substitution)
+	}
+	return escaped
+}
@@ -0,0 +1,38 @@
+package uri
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTrace(t *testing.T) {
+	template, err := Parse("{+base}/{id}{/path*}{?params*}{#frag}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, trace, err := template.ExpandTrace(map[string]interface{}{
+		"base":   "http://example.com",
+		"id":     "a b",
+		"path":   []interface{}{"x", "y/z"},
+		"params": Ordered{{"q", "1+1"}, {"lang", "en"}},
+		"frag":   "top",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://example.com/a%20b/x/y%2Fz?q=1%2B1&lang=en#top"; want != out {
+		t.Errorf("want %s, got %s", want, out)
+	}
+	want := []Substitution{
+		{Name: "base", Raw: "http://example.com", Escaped: "http://example.com", Operator: "+", Reserved: true},
+		{Name: "id", Raw: "a b", Escaped: "a%20b"},
+		{Name: "path", Raw: "x", Escaped: "x", Operator: "/"},
+		{Name: "path", Raw: "y/z", Escaped: "y%2Fz", Operator: "/"},
+		{Name: "params", Key: "q", Raw: "1+1", Escaped: "1%2B1", Operator: "?"},
+		{Name: "params", Key: "lang", Raw: "en", Escaped: "en", Operator: "?"},
+		{Name: "frag", Raw: "top", Escaped: "top", Operator: "#", Reserved: true},
+	}
+	if !reflect.DeepEqual(want, trace) {
+		t.Errorf("want %+v, got %+v", want, trace)
+	}
+}
@@ -14,93 +14,214 @@
 //	values["repo"] = "uritemplates"
 //	expanded, _ := template.Expand(values)
 //	fmt.Printf(expanded)
-//
 package uri
 
 import (
 	"bytes"
+	"encoding"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	validname = regexp.MustCompile("^([A-Za-z0-9_\\.]|%[0-9A-Fa-f][0-9A-Fa-f])+$")
+	hex       = []byte("0123456789ABCDEF")
+	lowerhex  = []byte("0123456789abcdef")
 )
 
+// A charset holds the ASCII characters that are left unencoded when values
+// are escaped. All other bytes are percent-encoded.
+type charset [utf8.RuneSelf]bool
+
 var (
-	unreserved = regexp.MustCompile("[^A-Za-z0-9\\-._~]")
-	reserved   = regexp.MustCompile("[^A-Za-z0-9\\-._~:/?#[\\]@!$&'()*+,;=]")
-	validname  = regexp.MustCompile("^([A-Za-z0-9_\\.]|%[0-9A-Fa-f][0-9A-Fa-f])+$")
-	hex        = []byte("0123456789ABCDEF")
+	unreserved     = makeCharset("")
+	pathsafe       = makeCharset("/")
+	strictreserved = makeCharset(":/?#[]@")
+	fragment       = makeCharset("!$&'()*+,;=:@/?")
+	reserved       = makeCharset(":/?#[]@!$&'()*+,;=")
 )
 
+// makeCharset returns the unreserved characters together with extra.
+func makeCharset(extra string) *charset {
+	var set charset
+	for c := 0; c < utf8.RuneSelf; c++ {
+		set[c] = isUnreserved(byte(c))
+	}
+	for i := 0; i < len(extra); i++ {
+		set[extra[i]] = true
+	}
+	return &set
+}
+
 func pctEncode(src []byte) []byte {
-	dst := make([]byte, len(src)*3)
-	for i, b := range src {
-		buf := dst[i*3 : i*3+3]
-		buf[0] = 0x25
-		buf[1] = hex[b/16]
-		buf[2] = hex[b%16]
+	return appendPctEncoded(make([]byte, 0, len(src)*3), string(src), hex)
+}
+
+func appendPctEncoded(dst []byte, src string, digits []byte) []byte {
+	for i := 0; i < len(src); i++ {
+		dst = append(dst, '%', digits[src[i]>>4], digits[src[i]&15])
 	}
 	return dst
 }
 
-func escape(s string, allowReserved bool) (escaped string) {
-	if allowReserved {
-		escaped = string(reserved.ReplaceAllFunc([]byte(s), pctEncode))
-	} else {
-		escaped = string(unreserved.ReplaceAllFunc([]byte(s), pctEncode))
+// appendEscaped appends s to dst, with the characters that are not in set
+// replaced by encode. Each call to encode receives the bytes of a single
+// character, or a single byte that is not valid UTF-8.
+func appendEscaped(dst []byte, s string, set *charset, encode func(dst []byte, src string) []byte) []byte {
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf && set[c] {
+			i++
+			continue
+		}
+		dst = append(dst, s[last:i]...)
+		size := 1
+		if c >= utf8.RuneSelf {
+			_, size = utf8.DecodeRuneInString(s[i:])
+		}
+		dst = encode(dst, s[i:i+size])
+		i += size
+		last = i
 	}
-	return escaped
+	return append(dst, s[last:]...)
 }
 
 // A UriTemplate is a parsed representation of a URI template.
+//
+// A Template is immutable once parsed: no method, including expansion and
+// matching, modifies it or caches state in it, so a single Template may be
+// shared by any number of goroutines. Methods that derive a differently
+// configured template, such as WithTruncateHandler and Join, return a new
+// one. The deprecated Set methods are the only exception; they must not be
+// called once the Template is shared.
 type Template struct {
-	raw   string
-	parts []templatePart
+	raw    string
+	parts  []templatePart
+	dotted bool
+	static bool
+
+	truncateHandler func(name, original, truncated string)
+	preProcess      func(map[string]interface{}) (map[string]interface{}, error)
+	constraints     map[string]Constraint
 }
 
-// Parse parses a URI template string into a UriTemplate object.
+// Parse parses a URI template string into a UriTemplate object. Every
+// variable name must be non-empty, so an empty expression such as "{}" or
+// "{a,}" is an error and an empty key in a value map is never expanded.
+// Errors are of type *ParseError.
 func Parse(raw string) (template *Template, err error) {
-	template = new(Template)
-	template.raw = raw
-	split := strings.Split(raw, "{")
-	template.parts = make([]templatePart, len(split)*2-1)
-	for i, s := range split {
-		if i == 0 {
-			if strings.Contains(s, "}") {
-				err = errors.New("unexpected }")
-				break
+	return parse(raw, false)
+}
+
+// MustParse is like Parse but panics if the template cannot be parsed. It
+// simplifies safe initialization of global variables holding templates.
+func MustParse(raw string) *Template {
+	template, err := Parse(raw)
+	if err != nil {
+		panic("uri: Parse(" + strconv.Quote(raw) + "): " + err.Error())
+	}
+	return template
+}
+
+// parse scans raw for expressions, which extend from a "{" to the next "}"
+// and may not contain another "{". The parts of the template alternate
+// between literals and expressions, starting and ending with a literal that
+// may be empty.
+func parse(raw string, dotted bool) (*Template, error) {
+	template := &Template{raw: raw, dotted: dotted}
+	start := 0
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '}':
+			return nil, &ParseError{Offset: i, Reason: ReasonUnexpectedClose, msg: "unexpected }"}
+		case '{':
+			end := strings.IndexAny(raw[i+1:], "{}")
+			if end < 0 {
+				return nil, &ParseError{Offset: i, Expr: raw[i+1:], Reason: ReasonUnclosed, msg: "unclosed expression"}
 			}
-			template.parts[i].raw = s
-		} else {
-			subsplit := strings.Split(s, "}")
-			if len(subsplit) != 2 {
-				err = errors.New("malformed template")
-				break
+			end += i + 1
+			expression := raw[i+1 : end]
+			if raw[end] == '{' {
+				return nil, &ParseError{Offset: end, Expr: expression, Reason: ReasonUnexpectedOpen, msg: "unexpected {"}
 			}
-			expression := subsplit[0]
-			template.parts[i*2-1], err = parseExpression(expression)
+			part, err := parseExpression(expression, dotted)
 			if err != nil {
-				break
+				perr := err.(*ParseError)
+				perr.Offset += i + 1
+				perr.Expr = expression
+				return nil, perr
 			}
-			template.parts[i*2].raw = subsplit[1]
+			template.parts = append(template.parts, templatePart{raw: raw[start:i]}, part)
+			i = end
+			start = end + 1
 		}
 	}
-	if err != nil {
-		template = nil
+	template.parts = append(template.parts, templatePart{raw: raw[start:]})
+	template.static = len(template.parts) == 1
+	template.markHosts()
+	return template, nil
+}
+
+// IsStatic reports whether the template contains no expressions, so that
+// every expansion produces the template text itself.
+func (t *Template) IsStatic() bool {
+	return t.static
+}
+
+// String returns the text the template was parsed from.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// GoString returns a description of the parsed template for debugging,
+// listing its literals and expressions in order, such as
+//
+//	uri.Template{"/users{/id}{?q,p:3}": "/users", {/ id}, {? q p:3}}
+func (t *Template) GoString() string {
+	var parts []string
+	for _, p := range t.parts {
+		if p.terms == nil {
+			if len(p.raw) > 0 {
+				parts = append(parts, strconv.Quote(p.raw))
+			}
+			continue
+		}
+		terms := make([]string, 0, len(p.terms)+1)
+		if p.op != 0 {
+			terms = append(terms, string(p.op))
+		}
+		for _, term := range p.terms {
+			terms = append(terms, term.String())
+		}
+		parts = append(parts, "{"+strings.Join(terms, " ")+"}")
+	}
+	if len(parts) == 0 {
+		return "uri.Template{" + strconv.Quote(t.raw) + "}"
 	}
-	return template, err
+	return "uri.Template{" + strconv.Quote(t.raw) + ": " + strings.Join(parts, ", ") + "}"
 }
 
 type templatePart struct {
 	raw           string
+	expr          string
+	op            byte
 	terms         []templateTerm
 	first         string
 	sep           string
 	named         bool
 	ifemp         string
 	allowReserved bool
+	host          bool
 }
 
 type templateTerm struct {
@@ -109,38 +230,55 @@ type templateTerm struct {
 	truncate int
 }
 
-func parseExpression(expression string) (result templatePart, err error) {
-	switch expression[0] {
+// parseExpression parses the text of an expression. Errors are *ParseError
+// values with an Offset relative to the start of the expression.
+func parseExpression(expression string, dotted bool) (result templatePart, err error) {
+	if len(expression) == 0 {
+		return result, &ParseError{Reason: ReasonEmpty, msg: "empty expression"}
+	}
+	result.expr = expression
+	op := expression[0]
+	if dotted && op == '.' && !strings.HasPrefix(expression, "..") {
+		op = 0
+	}
+	switch op {
 	case '+':
+		result.op = expression[0]
 		result.sep = ","
 		result.allowReserved = true
 		expression = expression[1:]
 	case '.':
+		result.op = expression[0]
 		result.first = "."
 		result.sep = "."
 		expression = expression[1:]
 	case '/':
+		result.op = expression[0]
 		result.first = "/"
 		result.sep = "/"
 		expression = expression[1:]
 	case ';':
+		result.op = expression[0]
 		result.first = ";"
 		result.sep = ";"
 		result.named = true
 		expression = expression[1:]
 	case '?':
+		result.op = expression[0]
 		result.first = "?"
 		result.sep = "&"
 		result.named = true
 		result.ifemp = "="
 		expression = expression[1:]
 	case '&':
+		result.op = expression[0]
 		result.first = "&"
 		result.sep = "&"
 		result.named = true
 		result.ifemp = "="
 		expression = expression[1:]
 	case '#':
+		result.op = expression[0]
 		result.first = "#"
 		result.sep = ","
 		result.allowReserved = true
@@ -148,101 +286,447 @@ func parseExpression(expression string) (result templatePart, err error) {
 	default:
 		result.sep = ","
 	}
+	offset := len(result.expr) - len(expression)
 	rawterms := strings.Split(expression, ",")
 	result.terms = make([]templateTerm, len(rawterms))
 	for i, raw := range rawterms {
 		result.terms[i], err = parseTerm(raw)
 		if err != nil {
+			err.(*ParseError).Offset += offset
 			break
 		}
+		offset += len(raw) + 1
 	}
 	return result, err
 }
 
 func parseTerm(term string) (result templateTerm, err error) {
+	raw := term
 	if strings.HasSuffix(term, "*") {
 		result.explode = true
 		term = term[:len(term)-1]
 	}
 	split := strings.Split(term, ":")
-	if len(split) == 1 {
-		result.name = term
+	result.name = split[0]
+	if len(split) > 2 {
+		return result, &ParseError{Offset: len(split[0]) + len(split[1]) + 1, Reason: ReasonInvalidPrefix, msg: "multiple colons in same term"}
 	} else if len(split) == 2 {
-		result.name = split[0]
-		var parsed int64
-		parsed, err = strconv.ParseInt(split[1], 10, 0)
+		parsed, perr := strconv.ParseInt(split[1], 10, 0)
+		if perr != nil {
+			return result, &ParseError{Offset: len(split[0]) + 1, Reason: ReasonInvalidPrefix, msg: "not a valid prefix: " + split[1]}
+		}
 		result.truncate = int(parsed)
-	} else {
-		err = errors.New("multiple colons in same term")
 	}
 	if !validname.MatchString(result.name) {
-		err = errors.New("not a valid name: " + result.name)
+		reason := ReasonInvalidName
+		if raw == "" {
+			reason = ReasonEmpty
+		}
+		return result, &ParseError{Reason: reason, msg: "not a valid name: " + result.name}
 	}
 	if result.explode && result.truncate > 0 {
-		err = errors.New("both explode and prefix modifers on same term")
+		return result, &ParseError{Offset: len(term), Reason: ReasonExplodePrefix, msg: "both explode and prefix modifers on same term"}
 	}
-	return result, err
+	return result, nil
 }
 
 // Expand expands a URI template with a set of values to produce a string.
+// The values are given as a map with string keys of any value type, such as
+// map[string]string or url.Values, or as a struct, or as a pointer to either.
+//
+// A value that is itself a *Template is expanded with the same values and
+// the result is used as a string value. Such nesting is limited to the depth
+// given by ExpandOpts.MaxDepth.
+//
+// An io.Reader value is read to completion and its contents are expanded as
+// a string, up to the size given by ExpandOpts.MaxReaderSize. A Lazy value
+// is computed only if its variable is referenced by the template.
+//
+// Slices and maps of any element type, and pointers to them, are expanded as
+// lists and associative arrays. Channels and iterator functions, such as
+// iter.Seq, are expanded as lists whose elements are consumed one at a time;
+// ExpandTo writes them out as they arrive rather than holding the whole
+// expression in memory, so SortQuery cannot sort an expression once part of
+// it has been written. Maps are expanded in ascending key order,
+// with keys that are not strings formatted as by fmt.Sprint, and structs in
+// the order their fields are declared. Use Ordered for any other order.
+//
+// Variables set to nil or to a nil pointer are undefined, as RFC 6570
+// describes, and other pointers are expanded as the value they point to.
+// ExpandOpts.LegacyNil restores the "<nil>" expansion of earlier versions.
+//
+// A time.Time value is formatted with ExpandOpts.TimeLayout. Other values
+// that implement encoding.TextMarshaler, including structs and elements of
+// lists and maps, are expanded as the text returned by MarshalText, and
+// those that implement fmt.Stringer or error as their String or Error
+// result, rather than field by field.
+//
+// When value is a struct, the fields of nested structs are also available
+// as variables named by their path, such as "user.name", joined with
+// ExpandOpts.NameSeparator.
 func (t *Template) Expand(value interface{}) (string, error) {
+	if t.static && t.preProcess == nil {
+		if err := t.checkValues(value); err != nil {
+			return "", err
+		}
+		return t.raw, nil
+	}
+	return t.ExpandWithOpts(value, ExpandOpts{})
+}
+
+// ExpandExcept expands a URI template like Expand, but treats the variables
+// named in skip as undefined even if value provides them.
+func (t *Template) ExpandExcept(value interface{}, skip []string) (string, error) {
+	e := &expander{opts: &ExpandOpts{}, skip: make(map[string]bool)}
+	for _, name := range skip {
+		e.skip[name] = true
+	}
+	return e.expand(t, value)
+}
+
+// ExpandMatching expands a URI template like Expand and returns an error if
+// the result does not match re.
+func (t *Template) ExpandMatching(value interface{}, re *regexp.Regexp) (string, error) {
+	expanded, err := t.Expand(value)
+	if err != nil {
+		return "", err
+	}
+	if !re.MatchString(expanded) {
+		return "", fmt.Errorf("expansion %q does not match %s", expanded, re)
+	}
+	return expanded, nil
+}
+
+// ExpandWithOpts expands a URI template like Expand, applying the given
+// options.
+func (t *Template) ExpandWithOpts(value interface{}, opts ExpandOpts) (string, error) {
+	e := &expander{opts: &opts}
+	return e.expand(t, value)
+}
+
+// ExpandTo expands a URI template like Expand and writes the result to w.
+// Each expression is expanded completely before it is written, so an
+// expression whose variables are all undefined writes nothing, not even its
+// operator prefix. If an error occurs, the expressions preceding it may
+// already have been written.
+//
+// ExpandTo does not allocate for values given as a map[string]interface{}
+// of strings that need no percent-encoding, unless w does.
+func (t *Template) ExpandTo(w io.Writer, value interface{}) error {
+	e := getExpander(&noOpts)
+	defer putExpander(e)
+	return e.expandTo(w, t, value)
+}
+
+// AppendExpand expands a URI template like Expand and appends the result to
+// dst, returning the extended buffer. If an error occurs, dst is returned
+// unchanged with the error. Like ExpandTo, AppendExpand does not allocate
+// for values given as a map[string]interface{} of strings that need no
+// percent-encoding, as long as dst has room for the result.
+func (t *Template) AppendExpand(dst []byte, value interface{}) ([]byte, error) {
+	e := getExpander(&noOpts)
+	defer putExpander(e)
+	buf := getBuffer(t.sizeHint())
+	defer putBuffer(buf)
+	if err := e.expandTo(buf, t, value); err != nil {
+		return dst, err
+	}
+	return append(dst, buf.Bytes()...), nil
+}
+
+func (e *expander) expand(t *Template, value interface{}) (string, error) {
+	buf := getBuffer(t.sizeHint())
+	defer putBuffer(buf)
+	if err := e.expandTo(buf, t, value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// prepare starts the expansion of t and returns its variables.
+func (e *expander) prepare(t *Template, value interface{}) (map[string]interface{}, error) {
+	e.template = t
+	e.chain = append(e.chain[:0], t.raw)
+	values, err := t.values(value, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	if t.preProcess != nil {
+		copied := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			copied[k] = v
+		}
+		if values, err = t.preProcess(copied); err != nil {
+			return nil, err
+		}
+	}
+	if e.opts.Strict {
+		if err := e.checkDefined(t, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (e *expander) expandTo(w io.Writer, t *Template, value interface{}) error {
+	if t.static && t.preProcess == nil {
+		if err := t.checkValues(value); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, t.raw)
+		return err
+	}
+	values, err := e.prepare(t, value)
+	if err != nil {
+		return err
+	}
+	if buf, isBuffer := w.(*bytes.Buffer); isBuffer {
+		for i := range t.parts {
+			if err := t.parts[i].expand(buf, values, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	buf := getBuffer(0)
+	defer putBuffer(buf)
+	e.w, e.partBuf = w, buf
+	for i := range t.parts {
+		buf.Reset()
+		e.flushed = false
+		if err := t.parts[i].expand(buf, values, e); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Template) values(value interface{}, opts *ExpandOpts) (map[string]interface{}, error) {
+	if t.dotted {
+		return t.resolveDotted(value, opts), nil
+	}
+	if isNil(value) {
+		return map[string]interface{}{}, nil
+	}
+	if query, isQuery := value.(url.Values); isQuery {
+		return queryValues(query), nil
+	}
 	values, isMap := value.(map[string]interface{})
 	if !isMap {
-		if m, isMap := struct2map(value); !isMap {
-			return "", errors.New("expected map[string]interface{}, struct, or pointer to struct.")
-		} else {
-			return t.Expand(m)
+		if values, isMap = stringMap(value); isMap {
+			return values, nil
+		}
+		if values, isMap = struct2map(value, opts); !isMap {
+			return nil, errExpectedMap
+		}
+		for name, v := range nestedFields(nil, values, "", opts, 0) {
+			if _, exists := values[name]; !exists {
+				values[name] = v
+			}
 		}
 	}
-	var buf bytes.Buffer
-	for _, p := range t.parts {
-		err := p.expand(&buf, values)
-		if err != nil {
-			return "", err
+	return values, nil
+}
+
+var errExpectedMap = errors.New("expected map with string keys, struct, or pointer to struct.")
+
+// checkValues returns the error that t.values returns for value, without
+// converting value, so that static templates reject the same values.
+func (t *Template) checkValues(value interface{}) error {
+	if t.dotted || isNil(value) {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Map {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+		return nil
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errExpectedMap
+	}
+	return nil
+}
+
+// queryValues converts url.Values to variables: a parameter with a single
+// value becomes a string, and one with several values a list.
+func queryValues(query url.Values) map[string]interface{} {
+	values := make(map[string]interface{}, len(query))
+	for k, vs := range query {
+		switch len(vs) {
+		case 0:
+		case 1:
+			values[k] = vs[0]
+		default:
+			a := make([]interface{}, len(vs))
+			for i, v := range vs {
+				a[i] = v
+			}
+			values[k] = a
 		}
 	}
-	return buf.String(), nil
+	return values
+}
+
+// stringMap copies a map with string keys, such as map[string]string or
+// url.Values, or a non-nil pointer to one, into a map[string]interface{}.
+func stringMap(value interface{}) (map[string]interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	values := make(map[string]interface{}, v.Len())
+	for _, k := range v.MapKeys() {
+		values[k.String()] = v.MapIndex(k).Interface()
+	}
+	return values, true
 }
 
-func (t *templatePart) expand(buf *bytes.Buffer, values map[string]interface{}) error {
+func (t *templatePart) expand(buf *bytes.Buffer, values map[string]interface{}, e *expander) error {
 	if len(t.raw) > 0 {
 		buf.WriteString(t.raw)
 		return nil
 	}
+	if e.sortQuery(t) {
+		outer := e.pairs
+		e.pairs = nil
+		defer func() { e.pairs = outer }()
+	}
 	var zeroLen = buf.Len()
 	buf.WriteString(t.first)
 	var firstLen = buf.Len()
+	var defined = 0
 	for _, term := range t.terms {
-		value, exists := values[term.name]
+		value, exists, err := e.lookup(values, term.name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if isNil(value) {
+			if !e.opts.LegacyNil || !printsNil(value) {
+				continue
+			}
+			value = "<nil>"
+		}
+		for _, hook := range e.opts.Hooks {
+			var err error
+			if value, err = hook.BeforeExpandVar(term.name, value); err != nil {
+				return err
+			}
+		}
+		if encode, hasEncoder := e.opts.Encoders[term.name]; hasEncoder && value != Null {
+			s, err := encode(value)
+			if err != nil {
+				return fmt.Errorf("%s: %v", term.name, err)
+			}
+			value = s
+		}
+		value, exists = normalize(value)
 		if !exists {
 			continue
 		}
-		if buf.Len() != firstLen {
-			buf.WriteString(t.sep)
+		if value != Null {
+			if err := e.checkValue(term.name, value); err != nil {
+				return err
+			}
 		}
+		if value == Null {
+			switch e.opts.Null {
+			case NullEmpty:
+				value = ""
+			case NullLiteral:
+				value = "null"
+			default:
+				continue
+			}
+		}
+		start, pairs := buf.Len(), len(e.pairs)
+		if defined > 0 {
+			e.writeSep(buf, t)
+		}
+		defined++
 		switch v := value.(type) {
+		case stream:
+			written, err := t.expandStream(buf, term, v, e)
+			if err != nil {
+				return err
+			}
+			if !written {
+				buf.Truncate(start)
+				e.pairs = e.pairs[:pairs]
+				defined--
+			}
 		case string:
-			t.expandString(buf, term, v)
+			t.expandString(buf, term, v, e)
+		case *Template:
+			s, err := e.expandNested(v, values)
+			if err != nil {
+				return err
+			}
+			t.expandString(buf, term, s, e)
+		case time.Time, encoding.TextMarshaler, fmt.Stringer, error:
+			s, err := e.format(term.name, v)
+			if err != nil {
+				return err
+			}
+			t.expandString(buf, term, s, e)
+		case io.Reader:
+			s, err := e.read(v)
+			if err != nil {
+				return err
+			}
+			t.expandString(buf, term, s, e)
 		case []interface{}:
-			t.expandArray(buf, term, v)
+			if err := t.expandArray(buf, term, v, e); err != nil {
+				return err
+			}
 		case map[string]interface{}:
 			if term.truncate > 0 {
 				return errors.New("cannot truncate a map expansion")
 			}
-			t.expandMap(buf, term, v)
+			if err := t.expandMap(buf, term, e.flatten(mapPairs(v)), e); err != nil {
+				return err
+			}
+		case Ordered:
+			if term.truncate > 0 {
+				return errors.New("cannot truncate a map expansion")
+			}
+			if err := t.expandMap(buf, term, e.flatten(v), e); err != nil {
+				return err
+			}
 		default:
-			if m, ismap := struct2map(value); ismap {
+			if pairs, ismap := struct2pairs(value, e.opts); ismap {
 				if term.truncate > 0 {
 					return errors.New("cannot truncate a map expansion")
 				}
-				t.expandMap(buf, term, m)
+				if err := t.expandMap(buf, term, e.flatten(pairs), e); err != nil {
+					return err
+				}
 			} else {
-				str := fmt.Sprintf("%v", value)
-				t.expandString(buf, term, str)
+				s, err := e.format(term.name, value)
+				if err != nil {
+					return err
+				}
+				t.expandString(buf, term, s, e)
 			}
 		}
 	}
-	if buf.Len() == firstLen {
+	if e.sortQuery(t) && !e.flushed {
+		sortPairs(buf, firstLen, e.sep(t), e.pairs)
+	}
+	if defined == 0 {
 		original := buf.Bytes()[:zeroLen]
 		buf.Reset()
 		buf.Write(original)
@@ -261,99 +745,273 @@ func (t *templatePart) expandName(buf *bytes.Buffer, name string, empty bool) {
 	}
 }
 
-func (t *templatePart) expandString(buf *bytes.Buffer, term templateTerm, s string) {
-	if len(s) > term.truncate && term.truncate > 0 {
-		s = s[:term.truncate]
+func (t *templatePart) expandString(buf *bytes.Buffer, term templateTerm, s string, e *expander) {
+	s = e.truncate(t, term, s)
+	if t.host && e.opts.IDNA {
+		if ascii, err := HostToASCII(s); err == nil {
+			s = ascii
+		}
 	}
 	t.expandName(buf, term.name, len(s) == 0)
-	buf.WriteString(escape(s, t.allowReserved))
+	buf.WriteString(e.escapeValue(t, term.name, "", s))
 }
 
-func (t *templatePart) expandArray(buf *bytes.Buffer, term templateTerm, a []interface{}) {
+func (t *templatePart) expandArray(buf *bytes.Buffer, term templateTerm, a []interface{}, e *expander) error {
 	if len(a) == 0 {
-		return
+		return nil
 	} else if !term.explode {
 		t.expandName(buf, term.name, false)
 	}
 	for i, value := range a {
 		if term.explode && i > 0 {
-			buf.WriteString(t.sep)
+			e.writeSep(buf, t)
 		} else if i > 0 {
 			buf.WriteString(",")
 		}
-		var s string
-		switch v := value.(type) {
-		case string:
-			s = v
-		default:
-			s = fmt.Sprintf("%v", v)
-		}
-		if len(s) > term.truncate && term.truncate > 0 {
-			s = s[:term.truncate]
+		s, err := e.format(term.name, value)
+		if err != nil {
+			return err
 		}
+		s = e.truncate(t, term, s)
 		if t.named && term.explode {
 			t.expandName(buf, term.name, len(s) == 0)
 		}
-		buf.WriteString(escape(s, t.allowReserved))
+		buf.WriteString(e.escapeValue(t, term.name, "", s))
 	}
+	return nil
 }
 
-func (t *templatePart) expandMap(buf *bytes.Buffer, term templateTerm, m map[string]interface{}) {
+func (t *templatePart) expandMap(buf *bytes.Buffer, term templateTerm, m Ordered, e *expander) error {
 	if len(m) == 0 {
-		return
+		return nil
 	}
 	if !term.explode {
 		t.expandName(buf, term.name, len(m) == 0)
 	}
 	var firstLen = buf.Len()
-	for k, value := range m {
+	for _, kv := range m {
+		k, value := kv.Key, kv.Value
 		if firstLen != buf.Len() {
 			if term.explode {
-				buf.WriteString(t.sep)
+				e.writeSep(buf, t)
 			} else {
 				buf.WriteString(",")
 			}
 		}
-		var s string
-		switch v := value.(type) {
-		case string:
-			s = v
-		default:
-			s = fmt.Sprintf("%v", v)
+		s, err := e.format(term.name, value)
+		if err != nil {
+			return err
 		}
-		if term.explode {
-			buf.WriteString(escape(k, t.allowReserved))
+		if term.explode && t.named {
+			t.expandName(buf, e.escape(t, k), len(s) == 0)
+			buf.WriteString(e.escapeValue(t, term.name, k, s))
+		} else if term.explode {
+			buf.WriteString(e.escape(t, k))
 			buf.WriteRune('=')
-			buf.WriteString(escape(s, t.allowReserved))
+			buf.WriteString(e.escapeValue(t, term.name, k, s))
 		} else {
-			buf.WriteString(escape(k, t.allowReserved))
+			buf.WriteString(e.escape(t, k))
 			buf.WriteRune(',')
-			buf.WriteString(escape(s, t.allowReserved))
+			buf.WriteString(e.escapeValue(t, term.name, k, s))
 		}
 	}
+	return nil
 }
 
-func struct2map(v interface{}) (map[string]interface{}, bool) {
-	value := reflect.ValueOf(v)
-	switch value.Type().Kind() {
-	case reflect.Ptr:
-		return struct2map(value.Elem().Interface())
-	case reflect.Struct:
-		m := make(map[string]interface{})
-		for i := 0; i < value.NumField(); i++ {
-			tag := value.Type().Field(i).Tag
-			var name string
-			if strings.Contains(string(tag), ":") {
-				name = tag.Get("uri")
-			} else {
-				name = strings.TrimSpace(string(tag))
+// A Pair is a single entry of an Ordered associative array.
+type Pair struct {
+	Key   string
+	Value interface{}
+}
+
+// Ordered is an associative array that is expanded in the order of its
+// pairs. Unlike a map it may hold the same key more than once, so it can
+// express repeated query and matrix parameters:
+//
+//	Ordered{{"tag", "a"}, {"tag", "b"}}
+//
+// expands "{?params*}" to "?tag=a&tag=b".
+type Ordered []Pair
+
+// mapPairs returns the entries of m as pairs in ascending key order, so that
+// expansions of maps are reproducible.
+func mapPairs(m map[string]interface{}) Ordered {
+	pairs := make(Ordered, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, Pair{k, v})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs
+}
+
+// flatten replaces pairs whose values are structs with the pairs of the
+// struct's fields, recursively, joining their keys with the NameSeparator.
+// Structs that format themselves, such as those implementing fmt.Stringer,
+// are kept as values.
+func (e *expander) flatten(pairs Ordered) Ordered {
+	var flattened Ordered
+	for i, kv := range pairs {
+		if !isScalar(kv.Value) && isStruct(kv.Value) {
+			if flattened == nil {
+				flattened = append(Ordered{}, pairs[:i]...)
+			}
+			fields, _ := struct2pairs(kv.Value, e.opts)
+			for _, sub := range e.flatten(fields) {
+				flattened = append(flattened, Pair{kv.Key + e.opts.nameSeparator() + sub.Key, sub.Value})
 			}
-			if len(name) == 0 {
-				name = value.Type().Field(i).Name
+		} else if flattened != nil {
+			flattened = append(flattened, kv)
+		}
+	}
+	if flattened == nil {
+		return pairs
+	}
+	return flattened
+}
+
+// isScalar reports whether value formats itself as a single string rather
+// than being expanded field by field.
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case encoding.TextMarshaler, fmt.Stringer, error:
+		return true
+	}
+	return false
+}
+
+func isStruct(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Struct
+}
+
+// normalize dereferences pointers to times, slices, arrays and maps and
+// converts typed slices, arrays and maps to the generic forms handled by
+// expand. Values that format themselves, such as a net.IP, are kept as is.
+// Maps with keys that are not strings become Ordered with keys formatted by
+// fmt.Sprint, in ascending key order, and url.Values become Ordered with one
+// pair per value. A nil pointer, and a list or map without elements, is
+// reported as undefined.
+func normalize(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case Ordered:
+		return v, len(v) > 0
+	case []Pair:
+		return Ordered(v), len(v) > 0
+	case url.Values:
+		var pairs Ordered
+		for _, k := range sortedKeys(v) {
+			for _, value := range v[k] {
+				pairs = append(pairs, Pair{k, value})
 			}
-			m[name] = value.Field(i).Interface()
 		}
-		return m, true
+		return pairs, len(pairs) > 0
+	}
+	if isNil(value) {
+		return nil, false
+	}
+	if t, isTime := value.(*time.Time); isTime {
+		return *t, true
+	}
+	if isScalar(value) {
+		return value, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() != reflect.Struct {
+		return normalize(v.Elem().Interface())
+	}
+	if s, isStream := toStream(v); isStream {
+		return s, true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if _, isArray := value.([]interface{}); !isArray {
+			a := make([]interface{}, v.Len())
+			for i := range a {
+				a[i] = v.Index(i).Interface()
+			}
+			value = a
+		}
+	case reflect.Map:
+		if _, isMap := value.(map[string]interface{}); isMap {
+			break
+		}
+		if v.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, v.Len())
+			for _, k := range v.MapKeys() {
+				m[k.String()] = v.MapIndex(k).Interface()
+			}
+			value = m
+			break
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessKey(keys[i], keys[j])
+		})
+		pairs := make(Ordered, len(keys))
+		for i, k := range keys {
+			pairs[i] = Pair{fmt.Sprint(k.Interface()), v.MapIndex(k).Interface()}
+		}
+		value = pairs
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		return v, len(v) > 0
+	case map[string]interface{}:
+		return v, len(v) > 0
+	case Ordered:
+		return v, len(v) > 0
+	}
+	return value, true
+}
+
+// isNil reports whether value is nil or a nil pointer.
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// printsNil reports whether the nil value was expanded as "<nil>" before nil
+// values became undefined, which ExpandOpts.LegacyNil restores. Nil pointers
+// to lists, maps and times were undefined already.
+func printsNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if _, isTime := value.(*time.Time); isTime {
+		return false
+	}
+	switch reflect.TypeOf(value).Elem().Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	return true
+}
+
+func sortedKeys(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
 	}
-	return nil, false
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
 }
@@ -1,8 +1,18 @@
 package uri
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestExpand(t *testing.T) {
@@ -38,3 +48,1654 @@ func TestExpand(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandWithOpts(t *testing.T) {
+	tests := []struct {
+		raw  string
+		opts ExpandOpts
+		args map[string]interface{}
+		out  string
+	}{
+		{"/files/{path}", ExpandOpts{}, map[string]interface{}{"path": "a/b/c"}, "/files/a%2Fb%2Fc"},
+		{"/files/{path}", ExpandOpts{Escaping: PathSafe}, map[string]interface{}{"path": "a/b/c"}, "/files/a/b/c"},
+		{"/files/{path}", ExpandOpts{Escaping: PathSafe}, map[string]interface{}{"path": "a b/c?d"}, "/files/a%20b/c%3Fd"},
+		{"/files{/path}", ExpandOpts{Escaping: PathSafe}, map[string]interface{}{"path": "a/b"}, "/files/a/b"},
+		{"{list*}", ExpandOpts{Separator: "\n"}, map[string]interface{}{"list": []interface{}{"/a b", "/c?d", "/e"}}, "%2Fa%20b\n%2Fc%3Fd\n%2Fe"},
+		{"{+list*}", ExpandOpts{Separator: "\n"}, map[string]interface{}{"list": []interface{}{"/a b", "/c"}}, "/a%20b\n/c"},
+		{"{x,y}", ExpandOpts{Separator: "\t"}, map[string]interface{}{"x": "1", "y": "2"}, "1\t2"},
+		{"/files/{path}", ExpandOpts{LowerHex: true}, map[string]interface{}{"path": "a/b c"}, "/files/a%2fb%20c"},
+		{"{+path}{?q}", ExpandOpts{LowerHex: true}, map[string]interface{}{"path": "/a b", "q": "\u00e9"}, "/a%20b?q=%c3%a9"},
+		{"{+path}{?q}", ExpandOpts{}, map[string]interface{}{"path": "/a b", "q": "\u00e9"}, "/a%20b?q=%C3%A9"},
+		{"/{b}/{a}{/d,c}{?z,y,x}", ExpandOpts{SortQuery: true}, map[string]interface{}{"a": "1", "b": "2", "c": "3", "d": "4", "x": "5", "y": "6", "z": "7"}, "/2/1/4/3?x=5&y=6&z=7"},
+		{"{?z,a,list*}{&b,a2}", ExpandOpts{SortQuery: true}, map[string]interface{}{"z": "1", "a": "2", "a2": "3", "b": "4", "list": []interface{}{"c", "b"}}, "?a=2&list=b&list=c&z=1&a2=3&b=4"},
+		{"{;z,a}", ExpandOpts{SortQuery: true}, map[string]interface{}{"z": "1", "a": "2"}, ";z=1;a=2"},
+		{"{?a,b}", ExpandOpts{SortQuery: true, Separator: ","}, map[string]interface{}{"a": []interface{}{"z", "y"}, "b": "c"}, "?a=z,y,b=c"},
+		{"{?b,a*}", ExpandOpts{SortQuery: true, Separator: ","}, map[string]interface{}{"a": []interface{}{"z", "y"}, "b": "c,d"}, "?a=y,a=z,b=c%2Cd"},
+		{"{?b,m*}", ExpandOpts{SortQuery: true, Separator: ";"}, map[string]interface{}{"m": map[string]interface{}{"y": "1", "x": "2"}, "b": "c"}, "?b=c;x=2;y=1"},
+		{"/{x}", ExpandOpts{NormalizePercent: true}, map[string]interface{}{"x": "%41%2F%2f%7e"}, "/A%2F%2F~"},
+		{"/{x}", ExpandOpts{}, map[string]interface{}{"x": "%41%2F"}, "/%2541%252F"},
+		{"/{x}", ExpandOpts{NormalizePercent: true}, map[string]interface{}{"x": "100% %zz %4"}, "/100%25%20%25zz%20%254"},
+		{"{+x}", ExpandOpts{NormalizePercent: true, LowerHex: true}, map[string]interface{}{"x": "/%61%2F b"}, "/a%2f%20b"},
+		{"/{x}{+y}", ExpandOpts{}, map[string]interface{}{"x": "a+b", "y": "/c+d"}, "/a%2Bb/c+d"},
+		{"/{x}{+y}", ExpandOpts{Plus: PlusPreserve}, map[string]interface{}{"x": "a+b c", "y": "/c+d"}, "/a+b%20c/c+d"},
+		{"/{x}{+y}", ExpandOpts{Plus: PlusEncode}, map[string]interface{}{"x": "a+b", "y": "/c+d"}, "/a%2Bb/c%2Bd"},
+		{"/{x}{+y}", ExpandOpts{Plus: PlusSpace}, map[string]interface{}{"x": "a+b", "y": "/c+d"}, "/a%20b/c%20d"},
+		{"{?q}", ExpandOpts{Plus: PlusEncode, LowerHex: true}, map[string]interface{}{"q": "1+1"}, "?q=1%2b1"},
+		{"/page{#f}", ExpandOpts{}, map[string]interface{}{"f": "a#b[c]/d?e"}, "/page#a#b[c]/d?e"},
+		{"/page{#f}", ExpandOpts{Escaping: FragmentSafe}, map[string]interface{}{"f": "a#b[c]/d?e"}, "/page#a%23b%5Bc%5D/d?e"},
+		{"/page{+p}{#f}", ExpandOpts{Escaping: FragmentSafe}, map[string]interface{}{"p": "/[x]", "f": "!$&'()*+,;=:@"}, "/page/[x]#!$&'()*+,;=:@"},
+		{"{?list*}", ExpandOpts{Separator: ";"}, map[string]interface{}{"list": []interface{}{"a", "b"}}, "?list=a;list=b"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(test.args, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandConcurrent(t *testing.T) {
+	template, err := Parse("http://localhost:8080/{id}{?date,name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("item %d", i)
+			want := fmt.Sprintf("http://localhost:8080/item%%20%d?date=2017-07-13&name=foo", i)
+			for j := 0; j < 100; j++ {
+				out, err := template.ExpandWithOpts(map[string]interface{}{"id": id, "date": "2017-07-13", "name": "foo"}, ExpandOpts{Escaping: Escaping(j % 2)})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if out != want {
+					t.Errorf("want %s, got %s", want, out)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestExpandExcept(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		skip []string
+		out  string
+	}{
+		{"http://localhost:8080/{?date,name}", map[string]interface{}{"date": "2017-07-13", "name": "foo"}, nil, "http://localhost:8080/?date=2017-07-13&name=foo"},
+		{"http://localhost:8080/{?date,name}", map[string]interface{}{"date": "2017-07-13", "name": "foo"}, []string{"name"}, "http://localhost:8080/?date=2017-07-13"},
+		{"http://localhost:8080/{?date,name}", map[string]interface{}{"date": "2017-07-13", "name": "foo"}, []string{"date", "name"}, "http://localhost:8080/"},
+		{"http://localhost:8080/{?date,name}", map[string]interface{}{"date": "2017-07-13"}, []string{"other"}, "http://localhost:8080/?date=2017-07-13"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandExcept(test.args, test.skip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+			if _, exists := test.args["date"]; !exists {
+				t.Errorf("values were modified")
+			}
+		})
+	}
+}
+
+func TestExpandMatching(t *testing.T) {
+	re := regexp.MustCompile("^https://api\\.example\\.com/")
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+		ok   bool
+	}{
+		{"https://api.example.com/{id}", map[string]interface{}{"id": "foo"}, "https://api.example.com/foo", true},
+		{"https://{host}/{id}", map[string]interface{}{"host": "api.example.com", "id": "foo"}, "https://api.example.com/foo", true},
+		{"https://{host}/{id}", map[string]interface{}{"host": "evil.example.com", "id": "foo"}, "", false},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandMatching(test.args, re)
+			if (err == nil) != test.ok {
+				t.Fatalf("want ok %t, got error %v", test.ok, err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandAlternatingInputs(t *testing.T) {
+	type query struct {
+		Date string `uri:"date"`
+		Name string `uri:"name"`
+	}
+	template, err := Parse("http://localhost:8080/{?date,name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		value interface{}
+		out   string
+	}{
+		{map[string]interface{}{"date": "2017-07-13"}, "http://localhost:8080/?date=2017-07-13"},
+		{query{Date: "2018-01-01", Name: "foo"}, "http://localhost:8080/?date=2018-01-01&name=foo"},
+		{map[string]interface{}{"name": "bar"}, "http://localhost:8080/?name=bar"},
+		{&query{Date: "2019-02-02", Name: "baz"}, "http://localhost:8080/?date=2019-02-02&name=baz"},
+		{map[string]interface{}{}, "http://localhost:8080/"},
+	}
+	for round := 0; round < 2; round++ {
+		for i, input := range inputs {
+			out, err := template.Expand(input.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if input.out != out {
+				t.Errorf("round %d, input %d: want %s, got %s", round, i, input.out, out)
+			}
+		}
+	}
+}
+
+func TestExpandWithIdempotencyKey(t *testing.T) {
+	template, err := Parse("http://localhost:8080/{id}{?date,name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expand := func(values map[string]interface{}) (string, string) {
+		url, key, err := template.ExpandWithIdempotencyKey(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return url, key
+	}
+	url1, key1 := expand(map[string]interface{}{"id": "foo", "date": "2017-07-13", "unused": 1})
+	url2, key2 := expand(map[string]interface{}{"date": "2017-07-13", "id": "foo", "unused": 2})
+	_, key3 := expand(map[string]interface{}{"id": "foo", "date": "2017-07-14"})
+	if url1 != "http://localhost:8080/foo?date=2017-07-13" || url1 != url2 {
+		t.Errorf("unexpected urls %s and %s", url1, url2)
+	}
+	if len(key1) != 64 {
+		t.Errorf("want a hex-encoded SHA-256 key, got %s", key1)
+	}
+	if key1 != key2 {
+		t.Errorf("same inputs produced different keys %s and %s", key1, key2)
+	}
+	if key1 == key3 {
+		t.Errorf("different inputs produced the same key %s", key1)
+	}
+}
+
+func TestExpandWithIdempotencyKeyCanonical(t *testing.T) {
+	template := MustParse("/{id}{?filter,tags}")
+	key := func(values map[string]interface{}) string {
+		_, key, err := template.ExpandWithIdempotencyKey(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	id1, id2 := "foo", "foo"
+	tags1, tags2 := []string{"a", "b"}, []string{"a", "b"}
+	key1 := key(map[string]interface{}{"id": &id1, "tags": &tags1, "filter": map[string]interface{}{"a": 1, "b": map[string]int{"x": 1, "y": 2}}})
+	key2 := key(map[string]interface{}{"id": &id2, "tags": &tags2, "filter": map[string]interface{}{"b": map[string]int{"y": 2, "x": 1}, "a": 1}})
+	if key1 != key2 {
+		t.Errorf("same inputs produced different keys %s and %s", key1, key2)
+	}
+	if key3 := key(map[string]interface{}{"id": &id1, "tags": &tags1, "filter": map[string]interface{}{"a": 1, "b": map[string]int{"x": 1, "y": 3}}}); key3 == key1 {
+		t.Errorf("different nested inputs produced the same key %s", key1)
+	}
+	for _, value := range []interface{}{
+		Lazy(func() (interface{}, error) { return "foo", nil }),
+		strings.NewReader("foo"),
+		make(chan string),
+	} {
+		if _, _, err := template.ExpandWithIdempotencyKey(map[string]interface{}{"id": value}); err == nil {
+			t.Errorf("%T: want an error", value)
+		}
+	}
+}
+
+func TestExpandWithIdempotencyKeyNilPointers(t *testing.T) {
+	template := MustParse("/events{?since,next}")
+	url1, key1, err := template.ExpandWithIdempotencyKey(map[string]interface{}{"since": (*time.Time)(nil), "next": (*url.URL)(nil)})
+	if err != nil || url1 != "/events" {
+		t.Fatalf("expected %q, got %q, %v", "/events", url1, err)
+	}
+	_, key2, err := template.ExpandWithIdempotencyKey(map[string]interface{}{"since": (*time.Time)(nil), "next": (*url.URL)(nil)})
+	if err != nil || key1 != key2 {
+		t.Errorf("same inputs produced different keys %s and %s, %v", key1, key2, err)
+	}
+}
+
+func TestExpandPointers(t *testing.T) {
+	type query struct {
+		List *[]string               `uri:"list"`
+		Keys *map[string]interface{} `uri:"keys"`
+	}
+	list := []string{"a", "b"}
+	keys := map[string]interface{}{"k": "v"}
+	tests := []struct {
+		raw   string
+		value query
+		out   string
+	}{
+		{"/{?list,keys}", query{}, "/"},
+		{"/{?list}", query{List: &list}, "/?list=a,b"},
+		{"/{?list*}", query{List: &list}, "/?list=a&list=b"},
+		{"/{?keys}", query{Keys: &keys}, "/?keys=k,v"},
+		{"/{?keys*}", query{Keys: &keys}, "/?k=v"},
+		{"/{?list,keys*}", query{List: &list, Keys: &keys}, "/?list=a,b&k=v"},
+		{"/{?list,keys*}", query{Keys: &keys}, "/?k=v"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.Expand(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandTypedLists(t *testing.T) {
+	type ids []int
+	array := [3]string{"x", "y", "z"}
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+	}{
+		{"{list}", []string{"a", "b", "c"}, "a,b,c"},
+		{"{/list*}", []string{"a", "b"}, "/a/b"},
+		{"{?list}", []int{1, 2, 3}, "?list=1,2,3"},
+		{"{?list*}", []float64{1.5, 2}, "?list=1.5&list=2"},
+		{"{;list*}", []bool{true, false}, ";list=true;list=false"},
+		{"{.list}", ids{4, 5}, ".4,5"},
+		{"{list}", array, "x,y,z"},
+		{"{?list*}", &array, "?list=x&list=y&list=z"},
+		{"{list}", [0]int{}, ""},
+		{"{list:1}", []string{"ab", "cd"}, "a,c"},
+		{"{list}", net.IPv4(10, 0, 0, 1), "10.0.0.1"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).Expand(map[string]interface{}{"list": test.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestSetTruncateHandler(t *testing.T) {
+	type truncation struct {
+		name, original, truncated string
+	}
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+		want []truncation
+	}{
+		{"/{x:3}", map[string]interface{}{"x": "abcdef"}, "/abc", []truncation{{"x", "abcdef", "abc"}}},
+		{"/{x:3}", map[string]interface{}{"x": "abc"}, "/abc", nil},
+		{"/{x:2,y}", map[string]interface{}{"x": "abc", "y": "abcdef"}, "/ab,abcdef", []truncation{{"x", "abc", "ab"}}},
+		{"/{?l:2}", map[string]interface{}{"l": []interface{}{"abc", "d", "efg"}}, "/?l=ab,d,ef", []truncation{{"l", "abc", "ab"}, {"l", "efg", "ef"}}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []truncation
+			template.SetTruncateHandler(func(name, original, truncated string) {
+				got = append(got, truncation{name, original, truncated})
+			})
+			out, err := template.Expand(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+			if fmt.Sprint(test.want) != fmt.Sprint(got) {
+				t.Errorf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestExpandPrefix(t *testing.T) {
+	values := map[string]interface{}{
+		"var":     "value",
+		"hello":   "Hello World!",
+		"path":    "/foo/bar",
+		"utf":     "\u00e4\u00f6\u00fc\u65e5\U0001F600",
+		"keys":    []string{"\u00e4bc", "d\u00e9f"},
+		"emojis":  "\U0001F600\U0001F601",
+		"combine": "e\u0301t\u00e9",
+	}
+	tests := []struct {
+		raw string
+		out string
+	}{
+		// RFC 6570 section 3.2
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{+path:6}/here", "/foo/b/here"},
+		{"{#path:6}/here", "#/foo/b/here"},
+		{"X{.var:3}", "X.val"},
+		{"{/var:1,var}", "/v/value"},
+		{";{;hello:5}", ";;hello=Hello"},
+		{"{?var:3}", "?var=val"},
+		{"{&var:3}", "&var=val"},
+		// code points, not bytes
+		{"{utf:1}", "%C3%A4"},
+		{"{utf:4}", "%C3%A4%C3%B6%C3%BC%E6%97%A5"},
+		{"{utf:5}", "%C3%A4%C3%B6%C3%BC%E6%97%A5%F0%9F%98%80"},
+		{"{emojis:1}", "%F0%9F%98%80"},
+		{"{?keys:2}", "?keys=%C3%A4b,d%C3%A9"},
+		{"{combine:2}", "e%CC%81"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).Expand(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandTriplets(t *testing.T) {
+	values := map[string]interface{}{
+		"half":  "50%",
+		"path":  "/a%20b%2fc",
+		"space": "%20%20%20",
+		"mixed": "a%2Gb%41",
+	}
+	tests := []struct {
+		raw  string
+		opts ExpandOpts
+		out  string
+	}{
+		{"{+half}", ExpandOpts{}, "50%25"},
+		{"{#half}", ExpandOpts{}, "#50%25"},
+		{"{half}", ExpandOpts{}, "50%25"},
+		{"{+path}", ExpandOpts{}, "/a%20b%2fc"},
+		{"{#path}", ExpandOpts{}, "#/a%20b%2fc"},
+		{"{path}", ExpandOpts{}, "%2Fa%2520b%252fc"},
+		{"{+path}", ExpandOpts{Escaping: OAuth1}, "%2Fa%2520b%252fc"},
+		{"{+mixed}", ExpandOpts{}, "a%252Gb%41"},
+		{"{+path}", ExpandOpts{NormalizePercent: true}, "/a%20b%2Fc"},
+		// a kept triplet counts as one character
+		{"{+space:2}", ExpandOpts{}, "%20%20"},
+		{"{#path:3}", ExpandOpts{}, "#/a%20"},
+		{"{+path:4}", ExpandOpts{}, "/a%20b"},
+		{"{space:2}", ExpandOpts{}, "%252"},
+		{"{space:2}", ExpandOpts{NormalizePercent: true}, "%20%20"},
+		{"{+mixed:3}", ExpandOpts{}, "a%252"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).ExpandWithOpts(values, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandNonStringKeys(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+	}{
+		{"{?m*}", map[string]interface{}{"m": map[int]string{10: "ten", 2: "two", -1: "minus one"}}, "?-1=minus%20one&2=two&10=ten"},
+		{"{?m}", map[string]interface{}{"m": map[int]string{2: "b", 1: "a"}}, "?m=1,a,2,b"},
+		{"{;m*}", map[string]interface{}{"m": map[float64]int{1.5: 1, 0.5: 2}}, ";0.5=2;1.5=1"},
+		{"{/m*}", map[string]interface{}{"m": map[bool]string{true: "yes", false: "no"}}, "/false=no/true=yes"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 10; j++ {
+				out, err := template.Expand(test.args)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.out != out {
+					t.Fatalf("want %s, got %s", test.out, out)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandPartiallyDefined(t *testing.T) {
+	empty := []interface{}{}
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+	}{
+		{"{?a,b,c}", map[string]interface{}{}, ""},
+		{"{?a,b,c}", map[string]interface{}{"a": "1"}, "?a=1"},
+		{"{?a,b,c}", map[string]interface{}{"b": "2"}, "?b=2"},
+		{"{?a,b,c}", map[string]interface{}{"c": "3"}, "?c=3"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "b": "2"}, "?a=1&b=2"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "c": "3"}, "?a=1&c=3"},
+		{"{?a,b,c}", map[string]interface{}{"b": "2", "c": "3"}, "?b=2&c=3"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "b": "2", "c": "3"}, "?a=1&b=2&c=3"},
+		{"{?a,b,c}", map[string]interface{}{"a": ""}, "?a="},
+		{"{?a,b,c}", map[string]interface{}{"c": ""}, "?c="},
+		{"{?a,b,c}", map[string]interface{}{"a": "", "b": "", "c": ""}, "?a=&b=&c="},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "b": "", "c": "3"}, "?a=1&b=&c=3"},
+		{"{?a,b,c}", map[string]interface{}{"a": empty}, ""},
+		{"{?a,b,c}", map[string]interface{}{"a": empty, "b": "2"}, "?b=2"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "b": empty, "c": "3"}, "?a=1&c=3"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "c": empty}, "?a=1"},
+		{"{?a,b,c}", map[string]interface{}{"a": "1", "b": map[string]interface{}{}, "c": "3"}, "?a=1&c=3"},
+		{"{?a*,b,c*}", map[string]interface{}{"a": empty, "b": "2", "c": map[string]interface{}{}}, "?b=2"},
+		{"{&a,b,c}", map[string]interface{}{"a": "1", "b": empty, "c": ""}, "&a=1&c="},
+		{"{;a,b,c}", map[string]interface{}{"a": "", "b": empty, "c": "3"}, ";a;c=3"},
+		{"{a,b,c}", map[string]interface{}{"a": "", "b": empty, "c": "3"}, ",3"},
+		{"{/a,b,c}", map[string]interface{}{"a": "1", "b": empty, "c": "3"}, "/1/3"},
+		{"{/a,b,c}", map[string]interface{}{"a": "1", "c": ""}, "/1/"},
+		{"X{.a,b,c}", map[string]interface{}{"b": ""}, "X."},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.Expand(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandJSONTags(t *testing.T) {
+	type query struct {
+		Date   string `json:"date"`
+		Name   string `json:"name,omitempty"`
+		Limit  int    `json:"limit,omitempty"`
+		Secret string `json:"-"`
+		Sort   string `json:"sort" uri:"order"`
+		Page   int
+	}
+	value := query{Date: "2017-07-13", Secret: "s", Sort: "asc", Page: 2}
+	tests := []struct {
+		raw  string
+		opts ExpandOpts
+		out  string
+	}{
+		{"/{?date,name,limit,Secret,order,Page}", ExpandOpts{JSONTags: true}, "/?date=2017-07-13&order=asc&Page=2"},
+		{"/{?Date,Name,Limit,Secret,order,Page}", ExpandOpts{}, "/?Date=2017-07-13&Name=&Limit=0&Secret=s&order=asc&Page=2"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(value, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+// writes records every call to Write separately.
+type writes []string
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestExpandTo(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+	}{
+		{"http://localhost:8080/{id}", map[string]interface{}{"id": "foo"}, "http://localhost:8080/foo"},
+		{"http://localhost:8080/{id}{?date,name}", map[string]interface{}{"id": "foo"}, "http://localhost:8080/foo"},
+		{"http://localhost:8080/{?date,name}{&page}", map[string]interface{}{"page": 2}, "http://localhost:8080/&page=2"},
+		{"{?date,name}", map[string]interface{}{}, ""},
+		{"{?date,name}", map[string]interface{}{"name": "foo"}, "?name=foo"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w writes
+			if err := template.ExpandTo(&w, test.args); err != nil {
+				t.Fatal(err)
+			}
+			out := strings.Join(w, "")
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+			for _, s := range w {
+				if s == "?" || s == "&" {
+					t.Errorf("wrote a dangling operator prefix: %q", w)
+				}
+			}
+		})
+	}
+	template, _ := Parse("/{id}")
+	if err := template.ExpandTo(failingWriter{}, map[string]interface{}{"id": "foo"}); err == nil {
+		t.Errorf("want write error")
+	}
+}
+
+func TestExpandOrdered(t *testing.T) {
+	params := Ordered{{"tag", "b"}, {"tag", "a"}, {"q", "x y"}, {"empty", ""}}
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+	}{
+		{"{?params*}", params, "?tag=b&tag=a&q=x%20y&empty="},
+		{"{?params}", params, "?params=tag,b,tag,a,q,x%20y,empty,"},
+		{"/x?v=1{&params*}", params, "/x?v=1&tag=b&tag=a&q=x%20y&empty="},
+		{"/x{;params*}", params, "/x;tag=b;tag=a;q=x%20y;empty"},
+		{"/x{.params*}", params, "/x.tag=b.tag=a.q=x%20y.empty="},
+		{"{?params*}", []Pair{{"a", 1}, {"a", 2}}, "?a=1&a=2"},
+		{"{?params*,limit}", Ordered{}, "?limit=10"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.Expand(map[string]interface{}{"params": test.value, "limit": 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestSetPreProcess(t *testing.T) {
+	template, err := Parse("/people/{fullName}{?first}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SetPreProcess(func(values map[string]interface{}) (map[string]interface{}, error) {
+		if values["first"] == "" {
+			return nil, errors.New("first name required")
+		}
+		values["fullName"] = fmt.Sprintf("%v %v", values["first"], values["last"])
+		delete(values, "first")
+		return values, nil
+	})
+	values := map[string]interface{}{"first": "Ada", "last": "Lovelace"}
+	out, err := template.Expand(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/people/Ada%20Lovelace"; want != out {
+		t.Errorf("want %s, got %s", want, out)
+	}
+	if len(values) != 2 || values["first"] != "Ada" {
+		t.Errorf("values were modified: %v", values)
+	}
+	if _, err := template.Expand(map[string]interface{}{"first": ""}); err == nil {
+		t.Errorf("want pre-processing error")
+	}
+}
+
+func TestParseEmptyNames(t *testing.T) {
+	for _, raw := range []string{"{}", "/{}/", "{,a}", "{a,}", "{a,,b}", "{?}", "{+}", "{:3}", "{*}", "{?a,}"} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("%s: want parse error", raw)
+		}
+	}
+	template, err := Parse("/{a}{?b}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := template.Expand(map[string]interface{}{"": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "/" {
+		t.Errorf("want /, got %s", out)
+	}
+}
+
+func TestExpandNestedTemplates(t *testing.T) {
+	base, err := Parse("https://{host}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := Parse("{+base}/users/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := Parse("{+users}{?q}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := template.Expand(map[string]interface{}{"base": base, "users": users, "host": "example.com", "id": "42", "q": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/users/42?q=x"; want != out {
+		t.Errorf("want %s, got %s", want, out)
+	}
+
+	a, _ := Parse("a{+b}")
+	b, _ := Parse("b{+a}")
+	values := map[string]interface{}{"a": a, "b": b}
+	_, err = a.ExpandWithOpts(values, ExpandOpts{MaxDepth: 3})
+	if err == nil {
+		t.Fatal("want depth error")
+	}
+	if want := "nested templates exceed depth 3: a{+b} -> b{+a} -> a{+b} -> b{+a} -> a{+b}"; err.Error() != want {
+		t.Errorf("want %q, got %q", want, err.Error())
+	}
+	if _, err := a.Expand(values); err == nil {
+		t.Error("want depth error with the default limit")
+	}
+	if _, err := users.ExpandWithOpts(map[string]interface{}{"base": base}, ExpandOpts{MaxDepth: 1}); err != nil {
+		t.Errorf("want one level of nesting to be allowed, got %v", err)
+	}
+}
+
+func TestExpandOAuth1(t *testing.T) {
+	// The request parameters of RFC 5849 section 3.4.1.3.
+	params := Ordered{
+		{"b5", "=%3D"},
+		{"a3", "a"},
+		{"c@", ""},
+		{"a2", "r b"},
+		{"oauth_consumer_key", "9djdj82h48djs9d2"},
+		{"oauth_token", "kkk9d7dh3k39sjv7"},
+		{"oauth_signature_method", "HMAC-SHA1"},
+		{"oauth_timestamp", "137131201"},
+		{"oauth_nonce", "7d8f3e4a"},
+		{"c2", ""},
+		{"a3", "2 q"},
+	}
+	tests := []struct {
+		raw  string
+		args map[string]interface{}
+		out  string
+	}{
+		{"{?params*}", map[string]interface{}{"params": params}, "?a2=r%20b&a3=2%20q&a3=a&b5=%3D%253D&c%40=&c2=&oauth_consumer_key=9djdj82h48djs9d2&oauth_nonce=7d8f3e4a&oauth_signature_method=HMAC-SHA1&oauth_timestamp=137131201&oauth_token=kkk9d7dh3k39sjv7"},
+		{"{+base}/request{?b5,a3}", map[string]interface{}{"base": "http://example.com", "b5": "=%3D", "a3": "a+b"}, "http%3A%2F%2Fexample.com/request?a3=a%2Bb&b5=%3D%253D"},
+		{"/{x}", map[string]interface{}{"x": "\u00e9~*"}, "/%C3%A9~%2A"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(test.args, ExpandOpts{Escaping: OAuth1, LowerHex: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandNestedStructs(t *testing.T) {
+	type geo struct {
+		Lat float64 `uri:"lat"`
+		Lng float64 `uri:"lng"`
+	}
+	type address struct {
+		Street string `uri:"street"`
+		Geo    geo    `uri:"geo"`
+	}
+	type location struct {
+		Address  address  `uri:"address"`
+		Fallback *address `uri:"fallback"`
+	}
+	value := location{
+		Address:  address{Street: "Main St", Geo: geo{Lat: 1.5, Lng: 2}},
+		Fallback: &address{Street: "Side St", Geo: geo{Lat: 3, Lng: 4}},
+	}
+	tests := []struct {
+		raw  string
+		sep  string
+		want []string
+	}{
+		{"{?address*}", "&", []string{"street=Main%20St", "geo.lat=1.5", "geo.lng=2"}},
+		{"{?fallback*}", "&", []string{"street=Side%20St", "geo.lat=3", "geo.lng=4"}},
+		{"{;address*}", ";", []string{"street=Main%20St", "geo.lat=1.5", "geo.lng=2"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.Expand(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sep := test.sep
+			got := strings.Split(out[1:], sep)
+			if len(got) != len(test.want) {
+				t.Fatalf("want %v, got %s", test.want, out)
+			}
+			for _, pair := range test.want {
+				if !strings.Contains(sep+out[1:]+sep, sep+pair+sep) {
+					t.Errorf("want %s in %s", pair, out)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandNestedStructNames(t *testing.T) {
+	type user struct {
+		Name string `uri:"name"`
+		ID   int    `uri:"id"`
+	}
+	type team struct {
+		Lead *user `uri:"lead"`
+	}
+	type request struct {
+		User user `uri:"user"`
+		Team team `uri:"team"`
+		Page int  `uri:"page"`
+	}
+	value := request{User: user{"ann", 7}, Team: team{&user{"bob", 8}}, Page: 2}
+	tests := []struct {
+		raw string
+		sep string
+		out string
+	}{
+		{"{?user.name,user.id,page}", "", "?user.name=ann&user.id=7&page=2"},
+		{"{?team.lead.name,team.lead.id}", "", "?team.lead.name=bob&team.lead.id=8"},
+		{"{?user_name,team_lead_id}", "_", "?user_name=ann&team_lead_id=8"},
+		{"{?user.name}", "_", ""},
+		{"{/user.name,user.missing}", "", "/ann"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).ExpandWithOpts(value, ExpandOpts{NameSeparator: test.sep})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+	out, err := MustParse("{?user*}").ExpandWithOpts(request{User: user{"ann", 7}}, ExpandOpts{NameSeparator: "_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "?name=ann&id=7"; out != want && out != "?id=7&name=ann" {
+		t.Errorf("want %s, got %s", want, out)
+	}
+}
+
+func TestExpandNull(t *testing.T) {
+	values := map[string]interface{}{"a": "1", "b": Null}
+	tests := []struct {
+		raw  string
+		mode NullMode
+		out  string
+	}{
+		{"/{a,b}", NullSkip, "/1"},
+		{"/{a,b}", NullEmpty, "/1,"},
+		{"/{a,b}", NullLiteral, "/1,null"},
+		{"/x{?a,b,c}", NullSkip, "/x?a=1"},
+		{"/x{?a,b,c}", NullEmpty, "/x?a=1&b="},
+		{"/x{?a,b,c}", NullLiteral, "/x?a=1&b=null"},
+		{"/x{;b}", NullSkip, "/x"},
+		{"/x{;b}", NullEmpty, "/x;b"},
+		{"/x{;b}", NullLiteral, "/x;b=null"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(values, ExpandOpts{Null: test.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestIsStatic(t *testing.T) {
+	tests := []struct {
+		raw    string
+		static bool
+	}{
+		{"", true},
+		{"http://localhost:8080/items", true},
+		{"http://localhost:8080/{id}", false},
+		{"{id}", false},
+	}
+	for _, test := range tests {
+		template, err := Parse(test.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if template.IsStatic() != test.static {
+			t.Errorf("%s: want static %t", test.raw, test.static)
+		}
+		if !test.static {
+			continue
+		}
+		for _, value := range []interface{}{nil, map[string]interface{}{"id": 1}, &struct{ ID int }{1}} {
+			out, err := template.Expand(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.raw {
+				t.Errorf("want %s, got %s", test.raw, out)
+			}
+		}
+		for _, value := range []interface{}{42, "x", []string{"a"}} {
+			if out, err := template.Expand(value); err == nil {
+				t.Errorf("%s: want an error for %#v, got %q", test.raw, value, out)
+			}
+			if out, err := template.Compile().Expand(value); err == nil {
+				t.Errorf("%s: compiled: want an error for %#v, got %q", test.raw, value, out)
+			}
+			if err := template.ExpandTo(io.Discard, value); err == nil {
+				t.Errorf("%s: ExpandTo: want an error for %#v", test.raw, value)
+			}
+		}
+	}
+}
+
+func BenchmarkExpandStatic(b *testing.B) {
+	template, err := Parse("http://localhost:8080/items")
+	if err != nil {
+		b.Fatal(err)
+	}
+	values := map[string]interface{}{"id": "foo"}
+	for i := 0; i < b.N; i++ {
+		template.Expand(values)
+	}
+}
+
+func TestExpandReader(t *testing.T) {
+	template, err := Parse("/{x}{?q}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := template.Expand(map[string]interface{}{"x": strings.NewReader("a b"), "q": strings.NewReader("c/d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/a%20b?q=c%2Fd"; want != out {
+		t.Errorf("want %s, got %s", want, out)
+	}
+	opts := ExpandOpts{MaxReaderSize: 4}
+	if _, err := template.ExpandWithOpts(map[string]interface{}{"x": strings.NewReader("abcd")}, opts); err != nil {
+		t.Errorf("want a reader of the maximum size to be allowed, got %v", err)
+	}
+	if _, err := template.ExpandWithOpts(map[string]interface{}{"x": strings.NewReader("abcde")}, opts); err == nil {
+		t.Errorf("want an error for a reader exceeding the maximum size")
+	}
+}
+
+func TestExpandNonASCII(t *testing.T) {
+	values := map[string]interface{}{"x": "\u00e4/\u65e5\U0001F600"}
+	want := "%C3%A4/%E6%97%A5%F0%9F%98%80"
+	for _, escaping := range []Escaping{DefaultEscaping, PathSafe, OAuth1, FragmentSafe, StrictReserved} {
+		for _, raw := range []string{"{+x}", "{#x}", "{x}", "{?x}"} {
+			template, err := Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(values, ExpandOpts{Escaping: escaping})
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded := strings.Replace(want, "/", "%2F", -1)
+			if !strings.Contains(out, want) && !strings.Contains(out, encoded) {
+				t.Errorf("%s with escaping %d: want non-ASCII bytes encoded, got %s", raw, escaping, out)
+			}
+		}
+	}
+}
+
+func TestExpandStrictReserved(t *testing.T) {
+	values := map[string]interface{}{"x": "/a:b@c?d=e&f;g,h+i!j$k'l(m)n*o[p]"}
+	tests := []struct {
+		raw      string
+		escaping Escaping
+		out      string
+	}{
+		{"{+x}", DefaultEscaping, "/a:b@c?d=e&f;g,h+i!j$k'l(m)n*o[p]"},
+		{"{+x}", StrictReserved, "/a:b@c?d%3De%26f%3Bg%2Ch%2Bi%21j%24k%27l%28m%29n%2Ao[p]"},
+		{"{#x}", StrictReserved, "#/a:b@c?d%3De%26f%3Bg%2Ch%2Bi%21j%24k%27l%28m%29n%2Ao[p]"},
+		{"{x}", StrictReserved, "%2Fa%3Ab%40c%3Fd%3De%26f%3Bg%2Ch%2Bi%21j%24k%27l%28m%29n%2Ao%5Bp%5D"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandWithOpts(values, ExpandOpts{Escaping: test.escaping})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	template := MustParse("http://localhost:8080/{id}")
+	if out, _ := template.Expand(map[string]interface{}{"id": "foo"}); out != "http://localhost:8080/foo" {
+		t.Errorf("want http://localhost:8080/foo, got %s", out)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("want panic")
+		}
+	}()
+	MustParse("http://localhost:8080/{id")
+}
+
+func TestAppendExpand(t *testing.T) {
+	template := MustParse("/{id}{?q}")
+	dst := make([]byte, 0, 64)
+	dst = append(dst, "http://localhost:8080"...)
+	out, err := template.AppendExpand(dst, map[string]interface{}{"id": "foo", "q": "a b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://localhost:8080/foo?q=a%20b"; string(out) != want {
+		t.Errorf("want %s, got %s", want, out)
+	}
+	if &out[0] != &dst[0] {
+		t.Errorf("want the expansion appended in place")
+	}
+	failed, err := template.AppendExpand(dst, 42)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if string(failed) != "http://localhost:8080" {
+		t.Errorf("want dst unchanged, got %s", failed)
+	}
+}
+
+func TestAppendExpandAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable with the race detector")
+	}
+	template := MustParse("http://localhost:8080/{id}{?date,name}")
+	compiled := template.Compile()
+	values := map[string]interface{}{"id": "foo", "date": "2017-07-13", "name": "bar"}
+	dst := make([]byte, 0, 128)
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"Template.AppendExpand", func() { template.AppendExpand(dst[:0], values) }},
+		{"Template.ExpandTo", func() { template.ExpandTo(io.Discard, values) }},
+		{"Compiled.AppendExpand", func() { compiled.AppendExpand(dst[:0], values) }},
+	}
+	for _, test := range tests {
+		if allocs := testing.AllocsPerRun(100, test.f); allocs != 0 {
+			t.Errorf("%s: want no allocations, got %v", test.name, allocs)
+		}
+	}
+}
+
+func BenchmarkExpand(b *testing.B) {
+	template := MustParse("http://localhost:8080/{id}{?date,name}")
+	values := map[string]interface{}{"id": "foo", "date": "2017-07-13", "name": "a b"}
+	b.Run("Expand", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			template.Expand(values)
+		}
+	})
+	b.Run("AppendExpand", func(b *testing.B) {
+		b.ReportAllocs()
+		dst := make([]byte, 0, 128)
+		for i := 0; i < b.N; i++ {
+			dst, _ = template.AppendExpand(dst[:0], values)
+		}
+	})
+	b.Run("ExpandTo", func(b *testing.B) {
+		b.ReportAllocs()
+		var w writes
+		for i := 0; i < b.N; i++ {
+			w = w[:0]
+			template.ExpandTo(&w, values)
+		}
+	})
+}
+
+func TestExpandTime(t *testing.T) {
+	since := time.Date(2017, 7, 13, 8, 30, 0, 0, time.UTC)
+	type query struct {
+		Since time.Time  `uri:"since,layout=2006-01-02"`
+		Until *time.Time `uri:"until,layout=Jan 2, 2006"`
+		At    time.Time  `uri:"at"`
+	}
+	tests := []struct {
+		raw    string
+		value  interface{}
+		layout string
+		out    string
+	}{
+		{"{?t}", map[string]interface{}{"t": since}, "", "?t=2017-07-13T08%3A30%3A00Z"},
+		{"{?t}", map[string]interface{}{"t": &since}, "", "?t=2017-07-13T08%3A30%3A00Z"},
+		{"{?t}", map[string]interface{}{"t": (*time.Time)(nil)}, "", ""},
+		{"{?t}", map[string]interface{}{"t": since}, "2006-01-02", "?t=2017-07-13"},
+		{"{?t*}", map[string]interface{}{"t": []time.Time{since, since.AddDate(0, 0, 1)}}, "2006-01-02", "?t=2017-07-13&t=2017-07-14"},
+		{"{?t*}", map[string]interface{}{"t": map[string]interface{}{"from": since}}, "2006-01-02", "?from=2017-07-13"},
+		{"{?since,until,at}", query{Since: since, Until: &since, At: since}, "", "?since=2017-07-13&until=Jul%2013%2C%202017&at=2017-07-13T08%3A30%3A00Z"},
+		{"{?since,until}", query{Since: since}, "2006", "?since=2017-07-13"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).ExpandWithOpts(test.value, ExpandOpts{TimeLayout: test.layout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+type textID [2]byte
+
+func (id textID) MarshalText() ([]byte, error) {
+	if id == (textID{}) {
+		return nil, errors.New("zero id")
+	}
+	return []byte(fmt.Sprintf("id-%x", id[:])), nil
+}
+
+type textColor struct {
+	R, G, B uint8
+}
+
+func (c *textColor) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)), nil
+}
+
+func TestExpandTextMarshaler(t *testing.T) {
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+		err   bool
+	}{
+		{"/{id}", textID{1, 2}, "/id-0102", false},
+		{"{?ids*}", []textID{{1, 2}, {3, 4}}, "?ids=id-0102&ids=id-0304", false},
+		{"{?m*}", map[string]interface{}{"a": textID{1, 2}}, "?a=id-0102", false},
+		{"{#c}", &textColor{255, 0, 16}, "##ff0010", false},
+		{"{?c*}", map[string]interface{}{"c": &textColor{0, 0, 0}}, "?c=%23000000", false},
+		{"{?c}", (*textColor)(nil), "", false},
+		{"/{id}", textID{}, "", true},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			value := map[string]interface{}{"id": test.value, "ids": test.value, "m": test.value, "c": test.value}
+			out, err := MustParse(test.raw).Expand(value)
+			if test.err {
+				if err == nil {
+					t.Errorf("want error, got %s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+type stringerPoint struct {
+	X, Y int
+}
+
+func (p stringerPoint) String() string {
+	return fmt.Sprintf("%d:%d", p.X, p.Y)
+}
+
+type errorStatus struct {
+	Code int
+}
+
+func (e *errorStatus) Error() string {
+	return fmt.Sprintf("status %d", e.Code)
+}
+
+func TestExpandStringer(t *testing.T) {
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+	}{
+		{"/{p}", stringerPoint{1, 2}, "/1%3A2"},
+		{"/{p*}", stringerPoint{1, 2}, "/1%3A2"},
+		{"{?p}", &stringerPoint{3, 4}, "?p=3%3A4"},
+		{"{?p*}", []stringerPoint{{1, 2}, {3, 4}}, "?p=1%3A2&p=3%3A4"},
+		{"{?p*}", map[string]interface{}{"at": stringerPoint{5, 6}}, "?at=5%3A6"},
+		{"{?p}", &errorStatus{404}, "?p=status%20404"},
+		{"{?p}", errors.New("not found"), "?p=not%20found"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).Expand(map[string]interface{}{"p": test.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandStringKeyedMaps(t *testing.T) {
+	type params map[string]interface{}
+	tests := []struct {
+		value interface{}
+		out   string
+	}{
+		{map[string]string{"id": "a", "q": "b c"}, "/a?q=b%20c"},
+		{&map[string]string{"id": "a"}, "/a"},
+		{map[string]int{"id": 1, "q": 2}, "/1?q=2"},
+		{map[string][]string{"id": {"a", "b"}}, "/a,b"},
+		{params{"id": "a"}, "/a"},
+	}
+	template := MustParse("/{id}{?q}")
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := template.Expand(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+	if _, err := template.Expand(map[int]string{1: "a"}); err == nil {
+		t.Error("want error for a map without string keys")
+	}
+}
+
+func TestExpandMapOrder(t *testing.T) {
+	type point struct {
+		Y int `uri:"y"`
+		X int `uri:"x"`
+	}
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+	}{
+		{"{?m*}", map[string]interface{}{"d": 4, "b": 2, "a": 1, "c": 3, "e": 5}, "?a=1&b=2&c=3&d=4&e=5"},
+		{"{?m}", map[string]string{"z": "1", "y": "2", "x": "3"}, "?m=x,3,y,2,z,1"},
+		{"{;m*}", map[string]interface{}{"p": point{2, 1}, "a": 0}, ";a=0;p.y=2;p.x=1"},
+		{"{/m*}", point{2, 1}, "/y=2/x=1"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template := MustParse(test.raw)
+			for j := 0; j < 20; j++ {
+				out, err := template.Expand(map[string]interface{}{"m": test.value})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.out != out {
+					t.Fatalf("want %s, got %s", test.out, out)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateString(t *testing.T) {
+	tests := []struct {
+		raw      string
+		goString string
+	}{
+		{"/users{/id}{?q,p:3}", `uri.Template{"/users{/id}{?q,p:3}": "/users", {/ id}, {? q p:3}}`},
+		{"{a}{+b*}x", `uri.Template{"{a}{+b*}x": {a}, {+ b*}, "x"}`},
+		{"", `uri.Template{""}`},
+	}
+	for _, test := range tests {
+		template := MustParse(test.raw)
+		if s := fmt.Sprint(template); s != test.raw {
+			t.Errorf("want %q, got %q", test.raw, s)
+		}
+		if s := fmt.Sprintf("%#v", template); s != test.goString {
+			t.Errorf("want %s, got %s", test.goString, s)
+		}
+	}
+}
+
+func TestExpandURLValues(t *testing.T) {
+	query := url.Values{"id": {"a"}, "q": {"x", "y"}, "e": {""}, "none": {}}
+	tests := []struct {
+		raw   string
+		value interface{}
+		out   string
+	}{
+		{"/{id}{?q}", query, "/a?q=x,y"},
+		{"/{id}{?q*}", query, "/a?q=x&q=y"},
+		{"{;e,none}", query, ";e"},
+		{"{?e,none}", query, "?e="},
+		{"{?p*}", map[string]interface{}{"p": query}, "?e=&id=a&q=x&q=y"},
+		{"{?p}", map[string]interface{}{"p": url.Values{"b": {"2"}, "a": {"1"}}}, "?p=a,1,b,2"},
+		{"{?p*}", map[string]interface{}{"p": url.Values{}}, ""},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			out, err := MustParse(test.raw).Expand(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandDefaults(t *testing.T) {
+	defaults := map[string]interface{}{"page": 1, "limit": 20, "sort": []string{"name", "date"}}
+	tests := []struct {
+		values map[string]interface{}
+		out    string
+	}{
+		{nil, "/items?page=1&limit=20&sort=name,date"},
+		{map[string]interface{}{"page": 3}, "/items?page=3&limit=20&sort=name,date"},
+		{map[string]interface{}{"sort": []string{}}, "/items?page=1&limit=20&sort=name,date"},
+		{map[string]interface{}{"limit": "", "sort": "date"}, "/items?page=1&limit=&sort=date"},
+		{map[string]interface{}{"page": Null}, "/items?limit=20&sort=name,date"},
+		{map[string]interface{}{"q": "go"}, "/items?q=go&page=1&limit=20&sort=name,date"},
+	}
+	template := MustParse("/items{?q,page,limit,sort}")
+	for i, test := range tests {
+		out, err := template.ExpandWithOpts(test.values, ExpandOpts{Defaults: defaults})
+		if err != nil || out != test.out {
+			t.Errorf("%d: want %s, got %s, %v", i, test.out, out, err)
+		}
+	}
+}
+
+func TestExpandEncoders(t *testing.T) {
+	encoders := map[string]func(interface{}) (string, error){
+		"cursor": func(v interface{}) (string, error) {
+			return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprint(v))), nil
+		},
+		"id": func(v interface{}) (string, error) {
+			b, ok := v.([]byte)
+			if !ok {
+				return "", fmt.Errorf("expected []byte, got %T", v)
+			}
+			return fmt.Sprintf("%x", b), nil
+		},
+	}
+	tests := []struct {
+		values map[string]interface{}
+		out    string
+		err    bool
+	}{
+		{map[string]interface{}{"id": []byte{0xca, 0xfe}, "cursor": "offset:20"}, "/items/cafe?cursor=b2Zmc2V0OjIw&q=a%2Fb", false},
+		{map[string]interface{}{"id": []byte{}}, "/items/?q=a%2Fb", false},
+		{map[string]interface{}{"cursor": Null}, "/items?q=a%2Fb", false},
+		{map[string]interface{}{"id": "cafe"}, "", true},
+	}
+	template := MustParse("/items{/id}{?cursor,q}")
+	for i, test := range tests {
+		test.values["q"] = "a/b"
+		out, err := template.ExpandWithOpts(test.values, ExpandOpts{Encoders: encoders})
+		if test.err {
+			if err == nil || !strings.HasPrefix(err.Error(), "id: ") {
+				t.Errorf("%d: expected an id error, got %q, %v", i, out, err)
+			}
+			continue
+		}
+		if err != nil || out != test.out {
+			t.Errorf("%d: want %s, got %s, %v", i, test.out, out, err)
+		}
+	}
+}
+
+type auditHook struct {
+	names []string
+}
+
+func (h *auditHook) BeforeExpandVar(name string, value interface{}) (interface{}, error) {
+	h.names = append(h.names, name)
+	return value, nil
+}
+
+func TestExpandHooks(t *testing.T) {
+	audit := &auditHook{}
+	trim := ExpandHookFunc(func(name string, value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok {
+			return strings.ToLower(strings.TrimSpace(s)), nil
+		}
+		return value, nil
+	})
+	validate := ExpandHookFunc(func(name string, value interface{}) (interface{}, error) {
+		if name == "id" && !regexp.MustCompile("^[a-z]+$").MatchString(fmt.Sprint(value)) {
+			return nil, fmt.Errorf("invalid id %q", value)
+		}
+		return value, nil
+	})
+	template := MustParse("/users{/id}{?q,tags}")
+	opts := ExpandOpts{Hooks: []ExpandHook{audit, trim, validate}}
+	out, err := template.ExpandWithOpts(map[string]interface{}{"id": " Ann ", "tags": []string{"A", "B"}}, opts)
+	if expected := "/users/ann?tags=A,B"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+	if expected := []string{"id", "tags"}; !reflect.DeepEqual(audit.names, expected) {
+		t.Errorf("want hooks called for %v, got %v", expected, audit.names)
+	}
+	if _, err := template.ExpandWithOpts(map[string]interface{}{"id": "a1"}, opts); err == nil || err.Error() != `invalid id "a1"` {
+		t.Errorf("want validation error, got %v", err)
+	}
+}
+
+func TestExpandNil(t *testing.T) {
+	s, n := "a b", 3
+	var nilString *string
+	var nilItems *[]string
+	type user struct {
+		Name *string
+		Age  *int
+	}
+	values := map[string]interface{}{
+		"nil":   nil,
+		"ptr":   nilString,
+		"items": nilItems,
+		"s":     &s,
+		"n":     &n,
+		"pp":    &nilString,
+		"user":  (*user)(nil),
+	}
+	tests := []struct {
+		raw    string
+		legacy bool
+		out    string
+	}{
+		{"/x{?nil,ptr,items,s}", false, "/x?s=a%20b"},
+		{"/x{?nil,ptr,items,s}", true, "/x?nil=%3Cnil%3E&ptr=%3Cnil%3E&s=a%20b"},
+		{"{/n,pp}", false, "/3"},
+		{"{;user*}", false, ""},
+		{"{#nil}", true, "#%3Cnil%3E"},
+	}
+	for i, test := range tests {
+		out, err := MustParse(test.raw).ExpandWithOpts(values, ExpandOpts{LegacyNil: test.legacy})
+		if err != nil || out != test.out {
+			t.Errorf("%d: want %s, got %s, %v", i, test.out, out, err)
+		}
+	}
+	out, err := MustParse("/users{/Name}{?Age}").Expand(&user{Name: &s})
+	if expected := "/users/a%20b"; err != nil || out != expected {
+		t.Errorf("want %s, got %s, %v", expected, out, err)
+	}
+	for _, value := range []interface{}{nil, (*user)(nil), map[string]interface{}(nil)} {
+		out, err := MustParse("/users{/Name}").Expand(value)
+		if err != nil || out != "/users" {
+			t.Errorf("Expand(%#v): want /users, got %s, %v", value, out, err)
+		}
+	}
+}
+
+func TestExpandWith(t *testing.T) {
+	template := MustParse("/items{/id}{?q,page,at,price}")
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	values := map[string]interface{}{"id": []byte("ab"), "q": "a b+c", "at": at, "price": 1e6}
+	tests := []struct {
+		opts []ExpandOption
+		out  string
+	}{
+		{nil, "/items/97,98?q=a%20b%2Bc&at=2024-05-06T07%3A08%3A09Z&price=1e%2B06"},
+		{[]ExpandOption{WithSortQuery(), WithDefaults(map[string]interface{}{"page": 1}), WithPlus(PlusEncode)}, "/items/97,98?at=2024-05-06T07%3A08%3A09Z&page=1&price=1e%2B06&q=a%20b%2Bc"},
+		{[]ExpandOption{WithEncoder("id", func(v interface{}) (string, error) { return fmt.Sprintf("%x", v), nil }), WithTimeLayout("2006-01-02"), WithNumbers(NumberFormat{Float: "f"})}, "/items/6162?q=a%20b%2Bc&at=2024-05-06&price=1000000"},
+		{[]ExpandOption{WithOpts(ExpandOpts{LowerHex: true, TimeLayout: "15:04"}), WithNumberFormat("price", NumberFormat{Float: "e1"})}, "/items/97,98?q=a%20b%2bc&at=07%3a08&price=1.0e%2b06"},
+	}
+	for i, test := range tests {
+		out, err := template.ExpandWith(values, test.opts...)
+		if err != nil || out != test.out {
+			t.Errorf("%d: want %s, got %s, %v", i, test.out, out, err)
+		}
+	}
+	if _, err := template.ExpandWith(values, WithStrict()); err == nil || err.Error() != "undefined variables: page" {
+		t.Errorf("want undefined page error, got %v", err)
+	}
+	if _, err := template.ExpandWith(values, WithStrict(), WithDefaults(map[string]interface{}{"page": 1})); err != nil {
+		t.Errorf("want page defined by its default, got %v", err)
+	}
+}
+
+func TestEscapeCharsets(t *testing.T) {
+	// The regular expressions that escaping used before the charset tables.
+	regexps := map[*charset]*regexp.Regexp{
+		unreserved:     regexp.MustCompile("[^A-Za-z0-9\\-._~]"),
+		pathsafe:       regexp.MustCompile("[^A-Za-z0-9\\-._~/]"),
+		strictreserved: regexp.MustCompile("[^A-Za-z0-9\\-._~:/?#[\\]@]"),
+		fragment:       regexp.MustCompile("[^A-Za-z0-9\\-._~!$&'()*+,;=:@/?]"),
+		reserved:       regexp.MustCompile("[^A-Za-z0-9\\-._~:/?#[\\]@!$&'()*+,;=]"),
+	}
+	var all []byte
+	for c := 0; c < 256; c++ {
+		all = append(all, byte(c))
+	}
+	inputs := []string{"", "abc", "a b/c?d=e&f#g", "ü€😀", "\xff\xfe", "a\xc3", "é\x80x", string(all)}
+	for _, opts := range []ExpandOpts{{}, {LowerHex: true}, {IRI: true}, {IRI: true, Escaping: OAuth1}} {
+		e := &expander{opts: &opts}
+		for set, re := range regexps {
+			for _, s := range inputs {
+				want := string(re.ReplaceAllFunc([]byte(s), e.encode))
+				if got := string(appendEscaped(nil, s, set, e.appendEncoded)); got != want {
+					t.Errorf("%+v, %s: escaping %q = %q, want %q", opts, re, s, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestTemplateConcurrentUse(t *testing.T) {
+	base := MustParse("https://{host}/items{/id}{?q:3,tags*}")
+	var truncated int64
+	var mu sync.Mutex
+	template := base.WithTruncateHandler(func(name, original, short string) {
+		mu.Lock()
+		truncated++
+		mu.Unlock()
+	}).WithPreProcess(func(values map[string]interface{}) (map[string]interface{}, error) {
+		values["host"] = "example.com"
+		return values, nil
+	})
+	if base.truncateHandler != nil || base.preProcess != nil {
+		t.Fatalf("With methods modified the original template")
+	}
+	compiled := template.Compile(WithSortQuery())
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values := map[string]interface{}{"id": i, "q": "query", "tags": []string{"a", "b"}}
+			want := fmt.Sprintf("https://example.com/items/%d?q=que&tags=a&tags=b", i)
+			for j := 0; j < 50; j++ {
+				if out, err := template.Expand(values); err != nil || out != want {
+					t.Errorf("want %s, got %s, %v", want, out, err)
+					return
+				}
+				if out, err := compiled.Expand(values); err != nil || out != want {
+					t.Errorf("compiled: want %s, got %s, %v", want, out, err)
+					return
+				}
+				if matched, ok := template.Match(want); !ok || matched["id"] != fmt.Sprint(i) {
+					t.Errorf("match %s: got %v, %v", want, matched, ok)
+					return
+				}
+				template.Regexp()
+				template.Segments()
+				template.Select("id").Join(MustParse("sub"))
+				template.RenameVar("q", "query")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if truncated != 16*50*2 {
+		t.Errorf("want %d truncations, got %d", 16*50*2, truncated)
+	}
+	if template.String() != base.String() || !template.Equal(base) {
+		t.Errorf("template changed during concurrent use: %#v", template)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"", "{", "}", "{}", "{{}}", "{a{b}", "/{a}/{b", "{a,}", "{,}", "{:}", "{*}", "{a:}", "{a:-1}",
+		"{a:99999999999999999999}", "{a*:3}", "{.}", "{..}", "{%}", "{%zz}", "{+a}{#b}",
+		"http://example.com/~{username}/", "https://{host}{/path*}{?q,lang:2}{&x*}{#frag}",
+	} {
+		f.Add(seed)
+	}
+	values := map[string]interface{}{
+		"a": "x y", "b": []string{"1", "2"}, "q": map[string]string{"k": "v"}, "host": "bücher.example", "path": []interface{}{"p", 7},
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		for _, dotted := range []bool{false, true} {
+			template, err := parse(raw, dotted)
+			if err != nil {
+				if _, ok := err.(*ParseError); !ok {
+					t.Fatalf("%q: error %v is not a *ParseError", raw, err)
+				}
+				if err.Error() == "" {
+					t.Fatalf("%q: empty error message", raw)
+				}
+				continue
+			}
+			if template.String() != raw {
+				t.Fatalf("%q: String() = %q", raw, template.String())
+			}
+			if canonical, err := parse(template.Canonical(), dotted); err != nil || !canonical.Equal(template) {
+				t.Fatalf("%q: canonical form %q does not parse equally: %v", raw, template.Canonical(), err)
+			}
+			out, err := template.Expand(values)
+			if err == nil {
+				template.Match(out)
+			}
+			template.ExpandWithOpts(values, ExpandOpts{Escaping: OAuth1, IDNA: true, Separator: "|"})
+			template.ExpandPartial(values)
+			template.Regexp()
+			template.Level()
+		}
+	})
+}
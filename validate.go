@@ -0,0 +1,81 @@
+package uri
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate reports problems that do not prevent the template from being
+// parsed but will produce malformed URIs when it is expanded. An empty
+// result means no problems were found.
+func (t *Template) Validate() []error {
+	var warnings []error
+	fragment := false
+	for _, p := range t.parts {
+		if p.terms == nil {
+			if strings.Contains(p.raw, "#") {
+				fragment = true
+			}
+			continue
+		}
+		switch p.op {
+		case '#':
+			fragment = true
+		case '?', '&':
+			if fragment {
+				warnings = append(warnings, fmt.Errorf("query expression {%s} follows a fragment", p.expr))
+			}
+		}
+	}
+	return warnings
+}
+
+// ValidateLevel returns an error if the template uses features beyond the
+// given RFC 6570 conformance level: level 1 allows only simple expressions
+// of a single variable, level 2 adds the "+" and "#" operators, level 3 adds
+// several variables per expression and the remaining operators, and level 4
+// adds the prefix and explode modifiers.
+func (t *Template) ValidateLevel(level int) error {
+	if level < 1 || level > 4 {
+		return fmt.Errorf("invalid level %d", level)
+	}
+	for _, p := range t.parts {
+		if p.terms == nil {
+			continue
+		}
+		if required := p.level(); required > level {
+			return fmt.Errorf("expression {%s} requires level %d", p.expr, required)
+		}
+	}
+	return nil
+}
+
+// Level returns the lowest RFC 6570 conformance level, from 1 to 4, that
+// supports every expression of the template, as described for ValidateLevel.
+// A static template is level 1.
+func (t *Template) Level() int {
+	level := 1
+	for _, p := range t.parts {
+		if p.terms != nil && p.level() > level {
+			level = p.level()
+		}
+	}
+	return level
+}
+
+// level returns the RFC 6570 conformance level that the expression p
+// requires.
+func (p *templatePart) level() int {
+	for _, term := range p.terms {
+		if term.explode || term.truncate > 0 {
+			return 4
+		}
+	}
+	switch {
+	case len(p.terms) > 1 || strings.IndexByte("./;?&", p.op) >= 0:
+		return 3
+	case p.op == '+' || p.op == '#':
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,87 @@
+package uri
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		raw      string
+		warnings int
+	}{
+		{"http://localhost:8080/{id}{?q}{#f}", 0},
+		{"http://localhost:8080/{id}?x=1{&q}", 0},
+		{"{#f}{?q}", 1},
+		{"{#f}{&q}", 1},
+		{"/path#frag{?q}", 1},
+		{"{#f}{?q}{&r}", 2},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			warnings := template.Validate()
+			if len(warnings) != test.warnings {
+				t.Errorf("want %d warnings, got %v", test.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateLevel(t *testing.T) {
+	tests := []struct {
+		raw   string
+		level int
+		err   string
+	}{
+		{"/static", 1, ""},
+		{"/users/{id}", 1, ""},
+		{"/users/{+path}", 1, "expression {+path} requires level 2"},
+		{"/users/{+path}{#f}", 2, ""},
+		{"/users/{a,b}", 2, "expression {a,b} requires level 3"},
+		{"/users{/id}", 2, "expression {/id} requires level 3"},
+		{"/users{?q,page}{&x}{.ext}{;m}", 3, ""},
+		{"/users{?q*}", 3, "expression {?q*} requires level 4"},
+		{"/users/{id:3}", 3, "expression {id:3} requires level 4"},
+		{"/users/{id:3}{/p*}", 4, ""},
+		{"/users", 0, "invalid level 0"},
+		{"/users", 5, "invalid level 5"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			err := MustParse(test.raw).ValidateLevel(test.level)
+			if test.err == "" && err != nil {
+				t.Errorf("want no error, got %v", err)
+			} else if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		raw   string
+		level int
+	}{
+		{"/static", 1},
+		{"/users/{id}", 1},
+		{"/users/{id}{#f}", 2},
+		{"{+a}/{b,c}", 3},
+		{"/users{?q}", 3},
+		{"/users{?q}{/p*}", 4},
+		{"/users/{id:2}", 4},
+	}
+	for _, test := range tests {
+		if level := MustParse(test.raw).Level(); level != test.level {
+			t.Errorf("%s: want level %d, got %d", test.raw, test.level, level)
+		}
+		if err := MustParse(test.raw).ValidateLevel(test.level); err != nil {
+			t.Errorf("%s: %v", test.raw, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package uri
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ExpandStrict expands a URI template like Expand, but returns an error
+// naming every variable of the template that value does not define.
+func (t *Template) ExpandStrict(value interface{}) (string, error) {
+	return t.ExpandWithOpts(value, ExpandOpts{Strict: true})
+}
+
+// checkDefined returns an error naming the variables of t that are not
+// defined by values or by the defaults of e. As in expansion, variables set
+// to nil, to a nil pointer or to an empty list or map are undefined.
+func (e *expander) checkDefined(t *Template, values map[string]interface{}) error {
+	var missing []string
+	for _, name := range t.Names() {
+		defined, err := e.defined(values, name)
+		if err != nil {
+			return err
+		}
+		if !defined {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("undefined variables: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// defined reports whether the variable name would be expanded.
+func (e *expander) defined(values map[string]interface{}, name string) (bool, error) {
+	value, exists, err := e.lookup(values, name)
+	if !exists || err != nil {
+		return false, err
+	}
+	if isNil(value) {
+		return e.opts.LegacyNil && printsNil(value), nil
+	}
+	_, defined := normalize(value)
+	return defined, nil
+}
+
+// FilterValues returns a new map holding only those values whose keys are
+// variables of the template or are listed in extras.
+func (t *Template) FilterValues(values map[string]interface{}, extras ...string) map[string]interface{} {
+	allowed := t.vars()
+	for _, name := range extras {
+		allowed[name] = true
+	}
+	filtered := make(map[string]interface{})
+	for k, v := range values {
+		if allowed[k] {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// UnusedKeys returns the sorted keys of values that are not variables of the
+// template, which often point to typos or stale configuration.
+func (t *Template) UnusedKeys(values map[string]interface{}) []string {
+	vars := t.vars()
+	var unused []string
+	for k := range values {
+		if !vars[k] {
+			unused = append(unused, k)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+func (t *Template) vars() map[string]bool {
+	vars := make(map[string]bool)
+	for _, p := range t.parts {
+		for _, term := range p.terms {
+			vars[term.name] = true
+		}
+	}
+	return vars
+}
@@ -0,0 +1,125 @@
+package uri
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFilterValues(t *testing.T) {
+	tests := []struct {
+		raw    string
+		values map[string]interface{}
+		extras []string
+		out    map[string]interface{}
+	}{
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "q": "x", "admin": true}, nil, map[string]interface{}{"id": 1, "q": "x"}},
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "trace": "t", "admin": true}, []string{"trace"}, map[string]interface{}{"id": 1, "trace": "t"}},
+		{"/static", map[string]interface{}{"id": 1}, nil, map[string]interface{}{}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := template.FilterValues(test.values, test.extras...)
+			if !reflect.DeepEqual(test.out, out) {
+				t.Errorf("want %v, got %v", test.out, out)
+			}
+			if _, exists := test.values["admin"]; i < 2 && !exists {
+				t.Errorf("values were modified")
+			}
+		})
+	}
+}
+
+func TestUnusedKeys(t *testing.T) {
+	tests := []struct {
+		raw    string
+		values map[string]interface{}
+		out    []string
+	}{
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "q": "x"}, nil},
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "qq": "x", "limt": 10}, []string{"limt", "qq"}},
+		{"/static", map[string]interface{}{"id": 1}, []string{"id"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := template.UnusedKeys(test.values)
+			if !reflect.DeepEqual(test.out, out) {
+				t.Errorf("want %v, got %v", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandStrict(t *testing.T) {
+	tests := []struct {
+		raw    string
+		values map[string]interface{}
+		out    string
+		err    string
+	}{
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "q": "x"}, "/1?q=x", ""},
+		{"/{id}{?q}", map[string]interface{}{"id": 1, "q": ""}, "/1?q=", ""},
+		{"/{id}{?q,limit}", map[string]interface{}{"id": 1, "qq": "x"}, "", "undefined variables: q, limit"},
+		{"/static", map[string]interface{}{}, "/static", ""},
+		{"/items/{id}", map[string]interface{}{"id": nil}, "", "undefined variables: id"},
+		{"/items/{id}", map[string]interface{}{"id": (*int)(nil)}, "", "undefined variables: id"},
+		{"/items{?tags,attrs}", map[string]interface{}{"tags": []string{}, "attrs": map[string]string{}}, "", "undefined variables: tags, attrs"},
+		{"/items{?tags}", map[string]interface{}{"tags": []string{"a"}}, "/items?tags=a", ""},
+		{"/items{?q}", map[string]interface{}{"q": Null}, "/items", ""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			template, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := template.ExpandStrict(test.values)
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("want error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.out != out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestExpandStrictLegacyNil(t *testing.T) {
+	out, err := MustParse("/items/{id}").ExpandWithOpts(map[string]interface{}{"id": nil}, ExpandOpts{Strict: true, LegacyNil: true})
+	if err != nil || out != "/items/%3Cnil%3E" {
+		t.Errorf("want /items/%%3Cnil%%3E, got %q, %v", out, err)
+	}
+}
+
+func TestExpandStrictPreProcess(t *testing.T) {
+	template := MustParse("/users/{full}").WithPreProcess(func(values map[string]interface{}) (map[string]interface{}, error) {
+		if first, ok := values["first"].(string); ok {
+			values["full"] = first + "." + values["last"].(string)
+		}
+		return values, nil
+	})
+	out, err := template.ExpandStrict(map[string]interface{}{"first": "ann", "last": "lee"})
+	if err != nil || out != "/users/ann.lee" {
+		t.Errorf("want /users/ann.lee, got %q, %v", out, err)
+	}
+	if _, err := template.ExpandStrict(map[string]interface{}{}); err == nil || err.Error() != "undefined variables: full" {
+		t.Errorf("want error %q, got %v", "undefined variables: full", err)
+	}
+}